@@ -0,0 +1,48 @@
+package pbnebula
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/cert"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+)
+
+// Re-export certificate audit types for external use
+type (
+	HostCertIssue  = sync.HostCertIssue  // Host whose stored credentials Nebula would reject
+	HostCertStatus = cert.HostCertStatus // Kind of problem found
+)
+
+// Host certificate statuses reported by AuditHostCertificates
+const (
+	HostCertMissing  = cert.HostCertMissing  // Certificate, private key or CA certificate not set
+	HostCertInvalid  = cert.HostCertInvalid  // PEM data cannot be parsed
+	HostCertMismatch = cert.HostCertMismatch // Not signed by the CA, key doesn't match, or CA replaced
+	HostCertExpired  = cert.HostCertExpired  // Host or CA certificate has expired
+	HostCertOrphaned = cert.HostCertOrphaned // Network or CA record no longer exists
+)
+
+// AuditHostCertificates verifies every stored host certificate against Nebula's CA pool.
+// It flags hosts whose certificate isn't signed by the stored CA, whose private key
+// doesn't match, that have expired, or whose network or CA record is gone.
+// Also available to superusers as GET /api/nebula/certificate-audit.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup (collection names)
+//   - networkID: Network record ID to audit (empty audits every host)
+//
+// RETURNS:
+// - []HostCertIssue with one entry per broken host (empty if all hosts are valid)
+// - error if the network or hosts cannot be loaded
+//
+// SIDE EFFECTS: None (read-only)
+func AuditHostCertificates(app *pocketbase.PocketBase, options Options, networkID string) ([]HostCertIssue, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.AuditHostCertificates(networkID)
+}
@@ -0,0 +1,54 @@
+package pbnebula
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+)
+
+// Re-export doctor types for external use
+type (
+	DoctorReport  = sync.DoctorReport  // Findings and repair results of a doctor run
+	DoctorFinding = sync.DoctorFinding // Single consistency problem
+)
+
+// Doctor checks and repair actions reported in DoctorFinding
+const (
+	DoctorCheckOutsideCIDR      = sync.CheckOutsideCIDR
+	DoctorCheckDuplicateIP      = sync.CheckDuplicateIP
+	DoctorCheckLighthouseNoAddr = sync.CheckLighthouseNoAddr
+	DoctorCheckStaleConfig      = sync.CheckStaleConfig
+	DoctorCheckCertGroups       = sync.CheckCertGroups
+	DoctorCheckInactiveNetwork  = sync.CheckInactiveNetwork
+	DoctorCheckHostCertificate  = sync.CheckHostCertificate
+	DoctorCheckHostExpiry       = sync.CheckHostExpiry
+	DoctorCheckCAExpiry         = sync.CheckCAExpiry
+	DoctorRepairResign          = sync.RepairResign
+	DoctorRepairRegenerate      = sync.RepairRegenerateConfig
+)
+
+// Doctor audits the whole database for inconsistent records: hosts outside their
+// network CIDR, duplicate IPs, lighthouses without endpoints, stale configs,
+// certificates whose groups differ from the groups field, hosts in inactive
+// networks, and expired or expiring host and CA certificates.
+// Also available as the `nebula doctor [--repair]` CLI command.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup (collection names)
+//   - repair: Re-sign or regenerate the hosts whose problems can be fixed that way
+//
+// RETURNS:
+// - DoctorReport with the findings and, when repairing, the results and remaining problems
+// - error if records cannot be loaded
+//
+// SIDE EFFECTS: Saves repaired host records (only with repair)
+func Doctor(app *pocketbase.PocketBase, options Options, repair bool) (*DoctorReport, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.Doctor(repair)
+}
@@ -4,6 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/skeeeon/pb-nebula/internal/config"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+	"github.com/skeeeon/pb-nebula/internal/types"
 )
 
 // Common errors returned by the library organized by operational category.
@@ -37,11 +42,20 @@ var (
 	ErrHostNotFound         = errors.New("host not found")
 	ErrInvalidIP            = errors.New("invalid IP address")
 	ErrIPNotInNetwork       = errors.New("IP address not within network CIDR")
-	ErrLighthouseNoPublicIP = errors.New("lighthouse hosts require public_host_port")
+	ErrLighthouseNoPublicIP = types.ErrLighthouseNoPublicIP
+	ErrRelayNoPublicIP      = types.ErrRelayNoPublicIP
+
+	// Endpoint errors - Malformed lighthouse/relay public endpoints
+	ErrInvalidEndpoint     = types.ErrInvalidEndpoint
+	ErrEndpointMissingPort = types.ErrEndpointMissingPort
+	ErrInvalidEndpointPort = types.ErrInvalidEndpointPort
+	ErrInvalidEndpointHost = types.ErrInvalidEndpointHost
 
 	// Config errors - Configuration generation
-	ErrConfigGeneration = errors.New("failed to generate config")
-	ErrInvalidFirewall  = errors.New("invalid firewall rules")
+	ErrConfigGeneration      = errors.New("failed to generate config")
+	ErrInvalidFirewall       = firewall.ErrInvalidFirewall
+	ErrInvalidConfig         = config.ErrInvalidConfig
+	ErrConfigsNotRegenerated = sync.ErrConfigsNotRegenerated
 
 	// Validation errors - Input validation
 	ErrInvalidOptions       = errors.New("invalid options provided")
@@ -140,6 +140,10 @@ func multiTenantExample() {
 	options1.CACollectionName = "tenant1_nebula_ca"
 	options1.NetworkCollectionName = "tenant1_nebula_networks"
 	options1.HostCollectionName = "tenant1_nebula_hosts"
+	options1.TemplateCollectionName = "tenant1_nebula_templates"
+	options1.PolicyCollectionName = "tenant1_nebula_firewall_policies"
+	options1.RuleSetCollectionName = "tenant1_nebula_firewall_rulesets"
+	options1.QueueCollectionName = "tenant1_nebula_regeneration_jobs"
 	if err := pbnebula.Setup(app, options1); err != nil {
 		log.Fatal(err)
 	}
@@ -149,6 +153,10 @@ func multiTenantExample() {
 	options2.CACollectionName = "tenant2_nebula_ca"
 	options2.NetworkCollectionName = "tenant2_nebula_networks"
 	options2.HostCollectionName = "tenant2_nebula_hosts"
+	options2.TemplateCollectionName = "tenant2_nebula_templates"
+	options2.PolicyCollectionName = "tenant2_nebula_firewall_policies"
+	options2.RuleSetCollectionName = "tenant2_nebula_firewall_rulesets"
+	options2.QueueCollectionName = "tenant2_nebula_regeneration_jobs"
 	if err := pbnebula.Setup(app, options2); err != nil {
 		log.Fatal(err)
 	}
@@ -2,10 +2,15 @@
 package cert
 
 import (
+	"crypto/ecdh"
 	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
+	"strings"
 	"time"
 
 	nebulacert "github.com/slackhq/nebula/cert"
@@ -23,16 +28,45 @@ import (
 // CURVE25519 ONLY:
 // For simplicity, we only support CURVE25519 (Ed25519 for signing, X25519 for ECDH).
 // This is Nebula's default and recommended curve.
+//
+// CLOCK SKEW:
+// NotBefore is backdated by a configurable window so hosts whose clocks run
+// slightly behind accept freshly issued certificates immediately.
 type Manager struct {
-	// Stateless - no fields needed
+	backdate time.Duration    // Subtracted from NotBefore to tolerate clock skew
+	now      func() time.Time // Clock used for validity calculations
 }
 
 // NewManager creates a new certificate manager.
 //
+// PARAMETERS:
+//   - backdate: How far NotBefore is moved into the past (0 or negative = no backdating)
+//
 // RETURNS:
 // - Manager instance ready for certificate operations
-func NewManager() *Manager {
-	return &Manager{}
+func NewManager(backdate time.Duration) *Manager {
+	return &Manager{
+		backdate: max(backdate, 0),
+		now:      time.Now,
+	}
+}
+
+// SetClock replaces the clock used for NotBefore, NotAfter and expiry checks.
+// This makes validity calculations deterministic in tests.
+//
+// PARAMETERS:
+//   - now: Function returning the current time (nil restores time.Now)
+func (m *Manager) SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	m.now = now
+}
+
+// Now returns the current time of the manager's clock (see SetClock).
+// Callers comparing certificate validity use it to agree with the manager.
+func (m *Manager) Now() time.Time {
+	return m.now()
 }
 
 // CAResult contains the generated CA certificate and keys.
@@ -40,6 +74,7 @@ type CAResult struct {
 	CertificatePEM string    // PEM encoded CA certificate (public)
 	PrivateKeyPEM  string    // PEM encoded CA private key (secret!)
 	ExpiresAt      time.Time // Certificate expiration timestamp
+	Fingerprint    string    // SHA256 fingerprint of the CA certificate
 }
 
 // HostCertResult contains the generated host certificate and keys.
@@ -47,6 +82,46 @@ type HostCertResult struct {
 	CertificatePEM string    // PEM encoded host certificate
 	PrivateKeyPEM  string    // PEM encoded host private key
 	ExpiresAt      time.Time // Certificate expiration timestamp
+	Fingerprint    string    // SHA256 fingerprint of the host certificate
+	Issuer         string    // Fingerprint of the signing CA
+}
+
+// CertificateInfo contains the decoded contents of a Nebula certificate.
+// This is what `nebula-cert print` shows, in a JSON friendly shape.
+type CertificateInfo struct {
+	Version        int       `json:"version"`         // Certificate format version (1 or 2)
+	Name           string    `json:"name"`            // Certificate name (CA name or hostname)
+	Networks       []string  `json:"networks"`        // Overlay networks (e.g., "10.128.0.100/16")
+	UnsafeNetworks []string  `json:"unsafe_networks"` // Unsafe routes the host may serve
+	Groups         []string  `json:"groups"`          // Groups used by firewall rules
+	IsCA           bool      `json:"is_ca"`           // True for CA certificates
+	Issuer         string    `json:"issuer"`          // Fingerprint of the signing CA (empty if self-signed)
+	Fingerprint    string    `json:"fingerprint"`     // SHA256 fingerprint of this certificate
+	PublicKey      string    `json:"public_key"`      // Hex encoded public key
+	Curve          string    `json:"curve"`           // Key curve (e.g., "CURVE25519")
+	NotBefore      time.Time `json:"not_before"`      // Start of validity period
+	NotAfter       time.Time `json:"not_after"`       // End of validity period
+	Expired        bool      `json:"expired"`         // True if NotAfter is in the past
+}
+
+// HostCertStatus classifies the stored credentials of a host (see VerifyHostCertificate).
+type HostCertStatus string
+
+const (
+	HostCertValid    HostCertStatus = "valid"    // Signed by the CA, unexpired, key matches
+	HostCertMissing  HostCertStatus = "missing"  // Certificate, private key or CA certificate not set
+	HostCertInvalid  HostCertStatus = "invalid"  // PEM data cannot be parsed
+	HostCertMismatch HostCertStatus = "mismatch" // Not signed by the CA, outside its constraints, or key doesn't match
+	HostCertExpired  HostCertStatus = "expired"  // Host certificate or CA certificate has expired
+	HostCertOrphaned HostCertStatus = "orphaned" // Network or CA record no longer exists (set by callers with database access)
+)
+
+// CAParams contains all parameters needed to generate a CA certificate.
+type CAParams struct {
+	Name            string   // Human-readable CA name
+	ValidityYears   int      // Certificate validity period
+	AllowedNetworks []string // Optional CIDRs host overlay IPs must fall within (empty = unrestricted)
+	AllowedGroups   []string // Optional groups host certificates may carry (empty = unrestricted)
 }
 
 // HostCertParams contains all parameters needed to generate a host certificate.
@@ -58,6 +133,7 @@ type HostCertParams struct {
 	CACertPEM       string    // CA certificate PEM (for signing)
 	CAPrivateKeyPEM string    // CA private key PEM (for signing)
 	CAExpiresAt     time.Time // CA expiration (host cert cannot outlive CA)
+	PublicKey       []byte    // Existing X25519 public key to sign (empty = generate new key pair)
 }
 
 // GenerateCA creates a new self-signed Nebula CA certificate.
@@ -66,43 +142,64 @@ type HostCertParams struct {
 // CA CHARACTERISTICS:
 // - Self-signed (no issuer)
 // - IsCA flag set to true
-// - No IP networks or groups (CAs don't have these)
+// - Optional networks/groups constraints (empty = may sign anything)
 // - Long validity period (default 10 years)
+// - NotBefore backdated by the configured clock skew window
+//
+// CA CONSTRAINTS:
+// Networks and groups on a CA certificate restrict what it may sign.
+// Nebula enforces this when signing and when verifying host certificates,
+// so constraints are fixed for the lifetime of the CA.
 //
 // KEY GENERATION:
 // Uses Ed25519 for signing (64 byte private key, 32 byte public key).
 // Keys are generated using crypto/rand for security.
 //
 // PARAMETERS:
-//   - name: Human-readable CA name
-//   - validityYears: Certificate validity period
+//   - params: All parameters needed for CA generation
 //
 // RETURNS:
 // - CAResult containing PEM encoded certificate and private key
-// - error if key generation or certificate signing fails
+// - error if constraints are invalid, key generation or certificate signing fails
 //
 // SIDE EFFECTS: None (pure generation)
-func (m *Manager) GenerateCA(name string, validityYears int) (*CAResult, error) {
+func (m *Manager) GenerateCA(params CAParams) (*CAResult, error) {
+	// Parse network constraints
+	networks := make([]netip.Prefix, 0, len(params.AllowedNetworks))
+	for _, cidr := range params.AllowedNetworks {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed network %q: %w", cidr, err)
+		}
+		if prefix != prefix.Masked() {
+			return nil, fmt.Errorf("allowed network %q is not a network address (should be %s)", cidr, prefix.Masked())
+		}
+		networks = append(networks, prefix)
+	}
+
 	// Generate Ed25519 key pair for CA
 	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate CA key pair: %w", err)
 	}
 
-	// Calculate validity period
-	notBefore := time.Now()
-	notAfter := notBefore.AddDate(validityYears, 0, 0)
+	// Calculate validity period (NotBefore backdated for clock skew, NotAfter from now)
+	now := m.now()
+	notBefore := now.Add(-m.backdate)
+	notAfter := now.AddDate(params.ValidityYears, 0, 0)
 
 	// Create TBSCertificate (To Be Signed certificate)
 	tbs := &nebulacert.TBSCertificate{
 		Version:   nebulacert.Version2,
-		Name:      name,
+		Name:      params.Name,
+		Networks:  networks,
+		Groups:    params.AllowedGroups,
 		IsCA:      true,
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
 		PublicKey: pubKey,
 		Curve:     nebulacert.Curve_CURVE25519,
-		// Networks, UnsafeNetworks, Groups are empty for CA
+		// UnsafeNetworks are empty for CA
 	}
 
 	// Self-sign the CA certificate (signer is nil for self-signed)
@@ -119,10 +216,16 @@ func (m *Manager) GenerateCA(name string, validityYears int) (*CAResult, error)
 
 	privKeyPEM := nebulacert.MarshalSigningPrivateKeyToPEM(nebulacert.Curve_CURVE25519, privKey)
 
+	fingerprint, err := certificate.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute CA fingerprint: %w", err)
+	}
+
 	return &CAResult{
 		CertificatePEM: string(certPEM),
 		PrivateKeyPEM:  string(privKeyPEM),
 		ExpiresAt:      notAfter,
+		Fingerprint:    fingerprint,
 	}, nil
 }
 
@@ -135,10 +238,17 @@ func (m *Manager) GenerateCA(name string, validityYears int) (*CAResult, error)
 // - Contains groups for firewall rules
 // - Signed by CA (contains issuer fingerprint)
 // - Validity cannot exceed CA validity
+// - NotBefore backdated by the clock skew window (never before the CA's NotBefore)
 //
-// KEY GENERATION:
-// Uses Ed25519 for signing (same as CA).
-// Each host gets a unique key pair.
+// KEY HANDLING:
+// - No PublicKey in params: A new X25519 key pair is generated (new key + new cert)
+// - PublicKey in params: The existing key is re-signed (new cert only, PrivateKeyPEM is empty)
+// Re-signing keeps the host's key stable across routine changes and allows
+// signing keys that never leave the client.
+//
+// CA CONSTRAINTS:
+// If the CA restricts networks or groups, the request is checked against them
+// before signing and rejected with a descriptive error.
 //
 // VALIDITY CONSTRAINT:
 // Host certificate expiration is the minimum of:
@@ -167,10 +277,23 @@ func (m *Manager) GenerateHostCert(params HostCertParams) (*HostCertResult, erro
 		return nil, fmt.Errorf("failed to parse CA private key: %w", err)
 	}
 
-	// Generate Ed25519 key pair for host
-	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate host key pair: %w", err)
+	// Check CA constraints up front for a readable error (signing would fail anyway)
+	if err := checkHostConstraints(caCert, params.OverlayIP, params.Groups); err != nil {
+		return nil, err
+	}
+
+	// Use the existing public key, or generate a fresh X25519 key pair for the host
+	pubKey := params.PublicKey
+	var privKeyPEM string
+	if len(pubKey) == 0 {
+		privKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate host key pair: %w", err)
+		}
+		pubKey = privKey.PublicKey().Bytes()
+		privKeyPEM = string(nebulacert.MarshalPrivateKeyToPEM(nebulacert.Curve_CURVE25519, privKey.Bytes()))
+	} else if len(pubKey) != 32 {
+		return nil, fmt.Errorf("invalid host public key length %d, expected 32", len(pubKey))
 	}
 
 	// Parse overlay IP and convert to /32 prefix
@@ -182,9 +305,15 @@ func (m *Manager) GenerateHostCert(params HostCertParams) (*HostCertResult, erro
 	// Create /32 prefix from IP (single host)
 	overlayPrefix := netip.PrefixFrom(addr, addr.BitLen())
 
+	// Backdate NotBefore for clock skew, but never before the CA became valid
+	now := m.now()
+	notBefore := now.Add(-m.backdate)
+	if notBefore.Before(caCert.NotBefore()) {
+		notBefore = caCert.NotBefore()
+	}
+
 	// Calculate expiration - min of requested or CA expiration
-	notBefore := time.Now()
-	requestedExpiry := notBefore.AddDate(params.ValidityYears, 0, 0)
+	requestedExpiry := now.AddDate(params.ValidityYears, 0, 0)
 
 	expiresAt := requestedExpiry
 	if requestedExpiry.After(params.CAExpiresAt) {
@@ -216,13 +345,246 @@ func (m *Manager) GenerateHostCert(params HostCertParams) (*HostCertResult, erro
 		return nil, fmt.Errorf("failed to marshal host certificate to PEM: %w", err)
 	}
 
-	// For host certificates, we use X25519 key format (not Ed25519 signing format)
-	// The private key is the same bytes, but the PEM banner is different
-	privKeyPEM := nebulacert.MarshalPrivateKeyToPEM(nebulacert.Curve_CURVE25519, privKey[:32])
+	fingerprint, err := certificate.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute host certificate fingerprint: %w", err)
+	}
 
 	return &HostCertResult{
 		CertificatePEM: string(certPEM),
-		PrivateKeyPEM:  string(privKeyPEM),
+		PrivateKeyPEM:  privKeyPEM,
 		ExpiresAt:      expiresAt,
+		Fingerprint:    fingerprint,
+		Issuer:         certificate.Issuer(),
+	}, nil
+}
+
+// CheckHostConstraints verifies a host's overlay IP and groups are allowed by the CA.
+// Used by request hooks to reject hosts the CA could never sign.
+//
+// PARAMETERS:
+//   - caCertPEM: PEM encoded CA certificate
+//   - overlayIP: Host overlay IP address
+//   - groups: Host groups
+//
+// RETURNS:
+// - nil if the CA has no constraints or the host satisfies them
+// - error naming the offending IP or group
+func (m *Manager) CheckHostConstraints(caCertPEM, overlayIP string, groups []string) error {
+	caCert, _, err := nebulacert.UnmarshalCertificateFromPEM([]byte(caCertPEM))
+	if err != nil {
+		return fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return checkHostConstraints(caCert, overlayIP, groups)
+}
+
+// checkHostConstraints compares host values against the networks and groups of a CA certificate.
+func checkHostConstraints(caCert nebulacert.Certificate, overlayIP string, groups []string) error {
+	if allowedGroups := caCert.Groups(); len(allowedGroups) > 0 {
+		for _, group := range groups {
+			if !slices.Contains(allowedGroups, group) {
+				return fmt.Errorf("group %q is not allowed by CA %q (allowed groups: %s)",
+					group, caCert.Name(), strings.Join(allowedGroups, ", "))
+			}
+		}
+	}
+
+	if allowedNetworks := caCert.Networks(); len(allowedNetworks) > 0 {
+		addr, err := netip.ParseAddr(overlayIP)
+		if err != nil {
+			return fmt.Errorf("invalid overlay IP %q: %w", overlayIP, err)
+		}
+
+		allowed := false
+		for _, network := range allowedNetworks {
+			if network.Contains(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("overlay IP %s is not allowed by CA %q (allowed networks: %s)",
+				overlayIP, caCert.Name(), strings.Join(prefixStrings(allowedNetworks), ", "))
+		}
+	}
+
+	return nil
+}
+
+// HostPublicKey extracts the public key from an existing host certificate.
+// Used to re-sign a host without changing its key pair.
+//
+// KEY PAIR CHECK:
+// If privateKeyPEM is provided, it must be the matching X25519 private key.
+// A mismatched pair is rejected so it isn't carried forward into a new certificate.
+//
+// PARAMETERS:
+//   - certPEM: PEM encoded host certificate
+//   - privateKeyPEM: PEM encoded host private key (optional, empty skips the check)
+//
+// RETURNS:
+// - []byte: Raw X25519 public key
+// - error if the certificate cannot be parsed or the key pair doesn't match
+func (m *Manager) HostPublicKey(certPEM, privateKeyPEM string) ([]byte, error) {
+	certificate, _, err := nebulacert.UnmarshalCertificateFromPEM([]byte(certPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse host certificate: %w", err)
+	}
+
+	if certificate.IsCA() {
+		return nil, fmt.Errorf("certificate %q is a CA certificate", certificate.Name())
+	}
+
+	if privateKeyPEM != "" {
+		privKey, _, curve, err := nebulacert.UnmarshalPrivateKeyFromPEM([]byte(privateKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host private key: %w", err)
+		}
+		if err := certificate.VerifyPrivateKey(curve, privKey); err != nil {
+			return nil, fmt.Errorf("host private key does not match certificate: %w", err)
+		}
+	}
+
+	return certificate.PublicKey(), nil
+}
+
+// VerifyHostChain checks host credentials the way Nebula does when it loads its pki section.
+//
+// VERIFICATION CHECKS (via Nebula's CA pool):
+// - The CA PEM contains at least one valid CA certificate
+// - The host certificate is signed by a CA in the pool and is within its validity period
+// - The host certificate satisfies the CA's constraints (networks, groups, validity)
+// - The private key matches the certificate
+//
+// PARAMETERS:
+//   - caCertPEM: PEM encoded CA certificate(s) (pki.ca)
+//   - certPEM: PEM encoded host certificate (pki.cert)
+//   - privateKeyPEM: PEM encoded host private key (pki.key)
+//
+// RETURNS:
+// - nil if Nebula would accept the credentials
+// - error describing the first problem found
+func (m *Manager) VerifyHostChain(caCertPEM, certPEM, privateKeyPEM string) error {
+	_, err := m.VerifyHostCertificate(caCertPEM, certPEM, privateKeyPEM)
+	return err
+}
+
+// VerifyHostCertificate runs the VerifyHostChain checks and classifies the outcome,
+// so stored host records can be audited (e.g. after database edits or restores).
+//
+// PARAMETERS:
+//   - caCertPEM: PEM encoded CA certificate(s) stored with the host
+//   - certPEM: PEM encoded host certificate
+//   - privateKeyPEM: PEM encoded host private key
+//
+// RETURNS:
+// - HostCertStatus: HostCertValid, or the kind of problem found
+// - error describing the problem, nil if the credentials are valid
+//
+// SIDE EFFECTS: None (pure verification)
+func (m *Manager) VerifyHostCertificate(caCertPEM, certPEM, privateKeyPEM string) (HostCertStatus, error) {
+	for _, value := range []struct{ name, pem string }{
+		{"CA certificate", caCertPEM},
+		{"certificate", certPEM},
+		{"private key", privateKeyPEM},
+	} {
+		if strings.TrimSpace(value.pem) == "" {
+			return HostCertMissing, fmt.Errorf("host %s is not set", value.name)
+		}
+	}
+
+	// An expired CA still loads - the pool is returned alongside ErrExpired
+	caPool, err := nebulacert.NewCAPoolFromPEM([]byte(caCertPEM))
+	if errors.Is(err, nebulacert.ErrExpired) {
+		return HostCertExpired, fmt.Errorf("CA certificate is expired: %w", err)
+	}
+	if err != nil {
+		return HostCertInvalid, fmt.Errorf("failed to load CA pool: %w", err)
+	}
+
+	certificate, _, err := nebulacert.UnmarshalCertificateFromPEM([]byte(certPEM))
+	if err != nil {
+		return HostCertInvalid, fmt.Errorf("failed to parse host certificate: %w", err)
+	}
+
+	if _, err := caPool.VerifyCertificate(m.now(), certificate); err != nil {
+		status := HostCertMismatch
+		if errors.Is(err, nebulacert.ErrExpired) || errors.Is(err, nebulacert.ErrRootExpired) {
+			status = HostCertExpired
+		}
+		return status, fmt.Errorf("host certificate %q failed verification against the CA: %w", certificate.Name(), err)
+	}
+
+	privKey, _, curve, err := nebulacert.UnmarshalPrivateKeyFromPEM([]byte(privateKeyPEM))
+	if err != nil {
+		return HostCertInvalid, fmt.Errorf("failed to parse host private key: %w", err)
+	}
+	if err := certificate.VerifyPrivateKey(curve, privKey); err != nil {
+		return HostCertMismatch, fmt.Errorf("host private key does not match certificate: %w", err)
+	}
+
+	return HostCertValid, nil
+}
+
+// ParseCertificate decodes a PEM encoded Nebula certificate for inspection.
+// This gives the same information as `nebula-cert print` without copying
+// the certificate out of the database.
+//
+// PARAMETERS:
+//   - certPEM: PEM encoded CA or host certificate
+//
+// RETURNS:
+// - CertificateInfo with the decoded certificate details
+// - error if the PEM cannot be parsed
+//
+// SIDE EFFECTS: None (pure parsing)
+func (m *Manager) ParseCertificate(certPEM string) (*CertificateInfo, error) {
+	if certPEM == "" {
+		return nil, fmt.Errorf("certificate is empty")
+	}
+
+	certificate, _, err := nebulacert.UnmarshalCertificateFromPEM([]byte(certPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	fingerprint, err := certificate.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute certificate fingerprint: %w", err)
+	}
+
+	return &CertificateInfo{
+		Version:        int(certificate.Version()),
+		Name:           certificate.Name(),
+		Networks:       prefixStrings(certificate.Networks()),
+		UnsafeNetworks: prefixStrings(certificate.UnsafeNetworks()),
+		Groups:         nonNilStrings(certificate.Groups()),
+		IsCA:           certificate.IsCA(),
+		Issuer:         certificate.Issuer(),
+		Fingerprint:    fingerprint,
+		PublicKey:      hex.EncodeToString(certificate.PublicKey()),
+		Curve:          certificate.Curve().String(),
+		NotBefore:      certificate.NotBefore(),
+		NotAfter:       certificate.NotAfter(),
+		Expired:        certificate.Expired(m.now()),
 	}, nil
 }
+
+// prefixStrings converts network prefixes to their string form.
+// Always returns a non-nil slice so JSON output is [] rather than null.
+func prefixStrings(prefixes []netip.Prefix) []string {
+	result := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		result[i] = prefix.String()
+	}
+	return result
+}
+
+// nonNilStrings returns an empty slice instead of nil for JSON output.
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
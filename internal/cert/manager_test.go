@@ -0,0 +1,208 @@
+package cert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedClock returns a clock frozen at t.
+func fixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// newTestCA generates a CA with the given manager, failing the test on error.
+func newTestCA(t *testing.T, m *Manager, params CAParams) *CAResult {
+	t.Helper()
+
+	if params.Name == "" {
+		params.Name = "test-ca"
+	}
+	if params.ValidityYears == 0 {
+		params.ValidityYears = 10
+	}
+
+	ca, err := m.GenerateCA(params)
+	if err != nil {
+		t.Fatalf("GenerateCA() error = %v", err)
+	}
+	return ca
+}
+
+// hostParams returns host certificate parameters signed by ca.
+func hostParams(ca *CAResult, overlayIP string, groups ...string) HostCertParams {
+	return HostCertParams{
+		Hostname:        "host-" + overlayIP,
+		OverlayIP:       overlayIP,
+		Groups:          groups,
+		ValidityYears:   1,
+		CACertPEM:       ca.CertificatePEM,
+		CAPrivateKeyPEM: ca.PrivateKeyPEM,
+		CAExpiresAt:     ca.ExpiresAt,
+	}
+}
+
+func TestGenerateBackdatesNotBefore(t *testing.T) {
+	// Certificates store whole seconds
+	now := time.Now().Truncate(time.Second)
+	backdate := 10 * time.Minute
+
+	m := NewManager(backdate)
+	m.SetClock(fixedClock(now))
+
+	ca := newTestCA(t, m, CAParams{})
+	caInfo, err := m.ParseCertificate(ca.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate(CA) error = %v", err)
+	}
+	if want := now.Add(-backdate); !caInfo.NotBefore.Equal(want) {
+		t.Errorf("CA NotBefore = %s, want %s", caInfo.NotBefore, want)
+	}
+
+	host, err := m.GenerateHostCert(hostParams(ca, "10.0.0.1"))
+	if err != nil {
+		t.Fatalf("GenerateHostCert() error = %v", err)
+	}
+	hostInfo, err := m.ParseCertificate(host.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate(host) error = %v", err)
+	}
+	if want := now.Add(-backdate); !hostInfo.NotBefore.Equal(want) {
+		t.Errorf("host NotBefore = %s, want %s", hostInfo.NotBefore, want)
+	}
+	if want := now.AddDate(1, 0, 0); !hostInfo.NotAfter.Equal(want) {
+		t.Errorf("host NotAfter = %s, want %s", hostInfo.NotAfter, want)
+	}
+}
+
+func TestGenerateHostCertBackdateStopsAtCANotBefore(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	caManager := NewManager(0)
+	caManager.SetClock(fixedClock(now))
+	ca := newTestCA(t, caManager, CAParams{})
+
+	// A wider backdate than the CA's must not produce a host valid before its CA
+	m := NewManager(time.Hour)
+	m.SetClock(fixedClock(now))
+
+	host, err := m.GenerateHostCert(hostParams(ca, "10.0.0.1"))
+	if err != nil {
+		t.Fatalf("GenerateHostCert() error = %v", err)
+	}
+	info, err := m.ParseCertificate(host.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !info.NotBefore.Equal(now) {
+		t.Errorf("host NotBefore = %s, want CA NotBefore %s", info.NotBefore, now)
+	}
+}
+
+func TestNegativeBackdateDisablesBackdating(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	m := NewManager(-time.Minute)
+	m.SetClock(fixedClock(now))
+
+	ca := newTestCA(t, m, CAParams{})
+	info, err := m.ParseCertificate(ca.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !info.NotBefore.Equal(now) {
+		t.Errorf("CA NotBefore = %s, want %s", info.NotBefore, now)
+	}
+}
+
+func TestGenerateHostCertRejectsCAConstraints(t *testing.T) {
+	m := NewManager(0)
+	ca := newTestCA(t, m, CAParams{
+		AllowedNetworks: []string{"10.0.0.0/24"},
+		AllowedGroups:   []string{"web", "ssh"},
+	})
+
+	tests := []struct {
+		name      string
+		overlayIP string
+		groups    []string
+		wantErr   string
+	}{
+		{name: "allowed", overlayIP: "10.0.0.5", groups: []string{"web"}},
+		{name: "network outside CA", overlayIP: "10.0.1.5", groups: []string{"web"}, wantErr: "overlay IP 10.0.1.5 is not allowed"},
+		{name: "group outside CA", overlayIP: "10.0.0.5", groups: []string{"web", "db"}, wantErr: `group "db" is not allowed`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.GenerateHostCert(hostParams(ca, tt.overlayIP, tt.groups...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("GenerateHostCert() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("GenerateHostCert() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+
+			// The request hooks use the same check
+			if err := m.CheckHostConstraints(ca.CertificatePEM, tt.overlayIP, tt.groups); err == nil {
+				t.Errorf("CheckHostConstraints() error = nil, want rejection")
+			}
+		})
+	}
+}
+
+func TestVerifyHostCertificateExpiry(t *testing.T) {
+	issued := time.Now().Truncate(time.Second)
+
+	m := NewManager(0)
+	m.SetClock(fixedClock(issued))
+
+	ca := newTestCA(t, m, CAParams{})
+	host, err := m.GenerateHostCert(hostParams(ca, "10.0.0.1"))
+	if err != nil {
+		t.Fatalf("GenerateHostCert() error = %v", err)
+	}
+
+	status, err := m.VerifyHostCertificate(ca.CertificatePEM, host.CertificatePEM, host.PrivateKeyPEM)
+	if status != HostCertValid {
+		t.Fatalf("VerifyHostCertificate() at issue time = %s (%v), want %s", status, err, HostCertValid)
+	}
+
+	// The host certificate is valid for 1 year, the CA for 10
+	m.SetClock(fixedClock(issued.AddDate(2, 0, 0)))
+
+	status, err = m.VerifyHostCertificate(ca.CertificatePEM, host.CertificatePEM, host.PrivateKeyPEM)
+	if status != HostCertExpired {
+		t.Errorf("VerifyHostCertificate() after expiry = %s (%v), want %s", status, err, HostCertExpired)
+	}
+
+	info, err := m.ParseCertificate(host.CertificatePEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !info.Expired {
+		t.Errorf("ParseCertificate().Expired = false after expiry, want true")
+	}
+}
+
+func TestGenerateHostCertCappedAtCAExpiry(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	m := NewManager(0)
+	m.SetClock(fixedClock(now))
+
+	ca := newTestCA(t, m, CAParams{ValidityYears: 1})
+	params := hostParams(ca, "10.0.0.1")
+	params.ValidityYears = 5
+
+	host, err := m.GenerateHostCert(params)
+	if err != nil {
+		t.Fatalf("GenerateHostCert() error = %v", err)
+	}
+	if !host.ExpiresAt.Equal(ca.ExpiresAt) {
+		t.Errorf("host ExpiresAt = %s, want CA expiry %s", host.ExpiresAt, ca.ExpiresAt)
+	}
+}
@@ -3,6 +3,7 @@ package collections
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
@@ -15,14 +16,22 @@ import (
 //
 // COLLECTION ARCHITECTURE:
 // - nebula_ca: Single CA record (root of trust, admin only)
+// - nebula_templates: User-supplied config templates (admin only)
 // - nebula_networks: Network definitions (isolation boundaries)
+// - nebula_firewall_policies: Network-level firewall policies (admin only)
+// - nebula_firewall_rulesets: Reusable firewall rule sets referenced by hosts (admin only)
 // - nebula_hosts: Host configurations (auth collection with Nebula credentials)
+// - nebula_regeneration_jobs: Pending background config regenerations (admin only)
 //
 // INITIALIZATION ORDER:
 // Collections must be created in dependency order to support foreign key relationships:
 // 1. CA (no dependencies)
-// 2. Networks (depends on CA)
-// 3. Hosts (depends on networks)
+// 2. Templates (no dependencies)
+// 3. Networks (depends on CA, templates)
+// 4. Firewall policies (depends on networks)
+// 5. Firewall rule sets (no dependencies)
+// 6. Hosts (depends on networks, templates, rule sets)
+// 7. Regeneration jobs (depends on hosts)
 type Manager struct {
 	app     *pocketbase.PocketBase // PocketBase instance for database operations
 	options pbtypes.Options        // Configuration options including collection names
@@ -48,13 +57,17 @@ func NewManager(app *pocketbase.PocketBase, options pbtypes.Options) *Manager {
 //
 // DEPENDENCY ORDER:
 // 1. CA (no dependencies)
-// 2. Networks (depends on CA)
-// 3. Hosts (depends on networks)
+// 2. Templates (no dependencies)
+// 3. Networks (depends on CA, templates)
+// 4. Firewall policies (depends on networks)
+// 5. Firewall rule sets (no dependencies)
+// 6. Hosts (depends on networks, templates, rule sets)
+// 7. Regeneration jobs (depends on hosts)
 //
 // IDEMPOTENT BEHAVIOR:
 // - Checks if collection exists before creating
 // - Skips creation if collection already exists
-// - Does not modify existing collection schemas
+// - Only adds fields missing from existing collections (never removes or changes fields)
 //
 // RETURNS:
 // - nil on successful initialization
@@ -65,14 +78,30 @@ func (cm *Manager) InitializeCollections() error {
 		return fmt.Errorf("failed to create CA collection: %w", err)
 	}
 
+	if err := cm.createTemplatesCollection(); err != nil {
+		return fmt.Errorf("failed to create templates collection: %w", err)
+	}
+
 	if err := cm.createNetworksCollection(); err != nil {
 		return fmt.Errorf("failed to create networks collection: %w", err)
 	}
 
+	if err := cm.createPoliciesCollection(); err != nil {
+		return fmt.Errorf("failed to create firewall policies collection: %w", err)
+	}
+
+	if err := cm.createRuleSetsCollection(); err != nil {
+		return fmt.Errorf("failed to create firewall rule sets collection: %w", err)
+	}
+
 	if err := cm.createHostsCollection(); err != nil {
 		return fmt.Errorf("failed to create hosts collection: %w", err)
 	}
 
+	if err := cm.createQueueCollection(); err != nil {
+		return fmt.Errorf("failed to create regeneration jobs collection: %w", err)
+	}
+
 	return nil
 }
 
@@ -89,6 +118,8 @@ func (cm *Manager) InitializeCollections() error {
 // - Identity fields: name
 // - Certificates: certificate, private_key (HIDDEN)
 // - Validity: validity_years, expires_at, curve
+// - Inspection: fingerprint, issuer (derived from certificate)
+// - Constraints: allowed_networks, allowed_groups (embedded in CA certificate)
 // - Metadata: created, updated timestamps
 //
 // RETURNS:
@@ -96,10 +127,10 @@ func (cm *Manager) InitializeCollections() error {
 // - error if collection creation fails
 func (cm *Manager) createCACollection() error {
 	// Check if collection already exists
-	_, err := cm.app.FindCollectionByNameOrId(cm.options.CACollectionName)
+	existing, err := cm.app.FindCollectionByNameOrId(cm.options.CACollectionName)
 	if err == nil {
-		// Collection already exists
-		return nil
+		// Collection already exists - only add fields introduced since it was created
+		return cm.ensureFields(existing, slices.Concat(certInfoFields(), caConstraintFields())...)
 	}
 
 	collection := core.NewBaseCollection(cm.options.CACollectionName)
@@ -139,6 +170,8 @@ func (cm *Manager) createCACollection() error {
 		Name: "curve",
 		Max:  50,
 	})
+	collection.Fields.Add(certInfoFields()...)
+	collection.Fields.Add(caConstraintFields()...)
 
 	// Add timestamps
 	collection.Fields.Add(&core.AutodateField{
@@ -159,6 +192,72 @@ func (cm *Manager) createCACollection() error {
 	return cm.app.Save(collection)
 }
 
+// createTemplatesCollection creates the config templates collection (admin only).
+// Templates replace the built-in Nebula config layout for networks and hosts they are assigned to.
+//
+// SECURITY MODEL:
+// - No public access rules (only admin can access)
+// - Templates see host private keys when rendered, so only admins may edit them
+//
+// SCHEMA:
+// - Identity: name (unique), description
+// - Template: content (Go text/template producing Nebula YAML)
+// - Metadata: created, updated timestamps
+//
+// RETURNS:
+// - nil if collection created successfully or already exists
+// - error if collection creation fails
+func (cm *Manager) createTemplatesCollection() error {
+	// Check if collection already exists
+	_, err := cm.app.FindCollectionByNameOrId(cm.options.TemplateCollectionName)
+	if err == nil {
+		// Collection already exists
+		return nil
+	}
+
+	collection := core.NewBaseCollection(cm.options.TemplateCollectionName)
+
+	// Admin only access - no public access
+	collection.ListRule = nil
+	collection.ViewRule = nil
+	collection.CreateRule = nil
+	collection.UpdateRule = nil
+	collection.DeleteRule = nil
+
+	// Add fields
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: true,
+		Max:      100,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "description",
+		Max:  500,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name:     "content",
+		Required: true,
+		Max:      50000,
+	})
+
+	// Add timestamps
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "updated",
+		OnCreate: true,
+		OnUpdate: true,
+	})
+
+	collection.Indexes = types.JSONArray[string]{
+		"CREATE UNIQUE INDEX idx_" + cm.options.TemplateCollectionName + "_name ON " + cm.options.TemplateCollectionName + " (name)",
+	}
+
+	return cm.app.Save(collection)
+}
+
 // createNetworksCollection creates the networks collection for tenant isolation.
 // Networks define CIDR ranges only - firewall rules are host-based in Nebula.
 //
@@ -176,7 +275,9 @@ func (cm *Manager) createCACollection() error {
 // SCHEMA:
 // - Identity: name, description
 // - Network: cidr_range (IPv4 only for now)
-// - Relation: ca_id (to nebula_ca)
+// - PKI: blocklist (retired certificate fingerprints)
+// - Config: config_overrides (merged over generated host configs)
+// - Relation: ca_id (to nebula_ca), template_id (optional, to nebula_templates)
 // - Management: active (enable/disable)
 // - Metadata: created, updated timestamps
 //
@@ -185,10 +286,14 @@ func (cm *Manager) createCACollection() error {
 // - error if collection creation fails
 func (cm *Manager) createNetworksCollection() error {
 	// Check if collection already exists
-	_, err := cm.app.FindCollectionByNameOrId(cm.options.NetworkCollectionName)
+	existing, err := cm.app.FindCollectionByNameOrId(cm.options.NetworkCollectionName)
 	if err == nil {
-		// Collection already exists
-		return nil
+		// Collection already exists - only add fields introduced since it was created
+		templateField, err := cm.templateRelationField()
+		if err != nil {
+			return err
+		}
+		return cm.ensureFields(existing, slices.Concat(networkPKIFields(), networkFirewallDefaultFields(), firewallSettingFields(), configOverrideFields(), []core.Field{templateField})...)
 	}
 
 	collection := core.NewBaseCollection(cm.options.NetworkCollectionName)
@@ -218,6 +323,18 @@ func (cm *Manager) createNetworksCollection() error {
 		Max:      50,
 	})
 
+	// Add certificate blocklist (distributed to every host in the network)
+	collection.Fields.Add(networkPKIFields()...)
+
+	// Add default firewall rules (empty mode = Options default)
+	collection.Fields.Add(networkFirewallDefaultFields()...)
+
+	// Add network-wide firewall actions and conntrack timeouts
+	collection.Fields.Add(firewallSettingFields()...)
+
+	// Add network-wide Nebula config overrides
+	collection.Fields.Add(configOverrideFields()...)
+
 	// Add management field
 	collection.Fields.Add(&core.BoolField{
 		Name: "active",
@@ -253,6 +370,13 @@ func (cm *Manager) createNetworksCollection() error {
 		CascadeDelete: false,
 	})
 
+	// Add optional relation to config template
+	templateField, err := cm.templateRelationField()
+	if err != nil {
+		return err
+	}
+	collection.Fields.Add(templateField)
+
 	// Create unique index on cidr_range
 	collection.Indexes = types.JSONArray[string]{
 		"CREATE UNIQUE INDEX idx_network_cidr ON " + cm.options.NetworkCollectionName + " (cidr_range)",
@@ -261,6 +385,178 @@ func (cm *Manager) createNetworksCollection() error {
 	return cm.app.Save(collection)
 }
 
+// createPoliciesCollection creates the firewall policies collection (admin only).
+// Policies are compiled into the effective firewall rules of matching hosts in their network.
+//
+// SECURITY MODEL:
+// - No public access rules (only admin can access)
+// - Policies open ports on many hosts at once, so only admins may edit them
+//
+// SCHEMA:
+// - Identity: name, description
+// - Policy: direction (inbound/outbound), target_groups (empty = all hosts), rules (Nebula format)
+// - Relation: network_id (to nebula_networks)
+// - Management: active (enable/disable)
+// - Metadata: created, updated timestamps
+//
+// RETURNS:
+// - nil if collection created successfully or already exists
+// - error if collection creation fails
+func (cm *Manager) createPoliciesCollection() error {
+	// Check if collection already exists
+	_, err := cm.app.FindCollectionByNameOrId(cm.options.PolicyCollectionName)
+	if err == nil {
+		// Collection already exists
+		return nil
+	}
+
+	networksCollection, err := cm.app.FindCollectionByNameOrId(cm.options.NetworkCollectionName)
+	if err != nil {
+		return fmt.Errorf("networks collection not found: %w", err)
+	}
+
+	collection := core.NewBaseCollection(cm.options.PolicyCollectionName)
+
+	// Admin only access - no public access
+	collection.ListRule = nil
+	collection.ViewRule = nil
+	collection.CreateRule = nil
+	collection.UpdateRule = nil
+	collection.DeleteRule = nil
+
+	// Add identity fields
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: true,
+		Max:      100,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "description",
+		Max:  500,
+	})
+
+	// Add policy fields
+	collection.Fields.Add(&core.RelationField{
+		Name:          "network_id",
+		Required:      true,
+		MaxSelect:     1,
+		CollectionId:  networksCollection.Id,
+		CascadeDelete: false,
+	})
+	collection.Fields.Add(&core.SelectField{
+		Name:      "direction",
+		Required:  true,
+		MaxSelect: 1,
+		Values:    []string{pbtypes.FirewallDirectionInbound, pbtypes.FirewallDirectionOutbound},
+	})
+	collection.Fields.Add(&core.JSONField{
+		Name:    "target_groups",
+		MaxSize: 2000,
+	})
+	collection.Fields.Add(&core.JSONField{
+		Name:     "rules",
+		Required: true,
+		MaxSize:  10000,
+	})
+
+	// Add management field
+	collection.Fields.Add(&core.BoolField{
+		Name: "active",
+	})
+
+	// Add timestamps
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "updated",
+		OnCreate: true,
+		OnUpdate: true,
+	})
+
+	return cm.app.Save(collection)
+}
+
+// createRuleSetsCollection creates the firewall rule sets collection (admin only).
+// Rule sets are named groups of rules (e.g. "ssh-from-admins") that hosts reference by id.
+//
+// SECURITY MODEL:
+// - No public access rules (only admin can access)
+// - Rule sets open ports on every referencing host, so only admins may edit them
+//
+// SCHEMA:
+// - Identity: name (unique), description
+// - Rules: outbound, inbound (Nebula format)
+// - Versioning: version (incremented by the sync layer on every rule change)
+// - Metadata: created, updated timestamps
+//
+// RETURNS:
+// - nil if collection created successfully or already exists
+// - error if collection creation fails
+func (cm *Manager) createRuleSetsCollection() error {
+	// Check if collection already exists
+	_, err := cm.app.FindCollectionByNameOrId(cm.options.RuleSetCollectionName)
+	if err == nil {
+		// Collection already exists
+		return nil
+	}
+
+	collection := core.NewBaseCollection(cm.options.RuleSetCollectionName)
+
+	// Admin only access - no public access
+	collection.ListRule = nil
+	collection.ViewRule = nil
+	collection.CreateRule = nil
+	collection.UpdateRule = nil
+	collection.DeleteRule = nil
+
+	// Add identity fields
+	collection.Fields.Add(&core.TextField{
+		Name:     "name",
+		Required: true,
+		Max:      100,
+	})
+	collection.Fields.Add(&core.TextField{
+		Name: "description",
+		Max:  500,
+	})
+
+	// Add rules (Nebula native JSON format)
+	collection.Fields.Add(&core.JSONField{
+		Name:    "outbound",
+		MaxSize: 10000,
+	})
+	collection.Fields.Add(&core.JSONField{
+		Name:    "inbound",
+		MaxSize: 10000,
+	})
+
+	// Add version (maintained by the sync layer)
+	collection.Fields.Add(&core.NumberField{
+		Name:    "version",
+		OnlyInt: true,
+		Min:     types.Pointer(0.0),
+	})
+
+	// Add timestamps
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "updated",
+		OnCreate: true,
+		OnUpdate: true,
+	})
+
+	collection.Indexes = types.JSONArray[string]{
+		"CREATE UNIQUE INDEX idx_" + cm.options.RuleSetCollectionName + "_name ON " + cm.options.RuleSetCollectionName + " (name)",
+	}
+
+	return cm.app.Save(collection)
+}
+
 // createHostsCollection creates the hosts collection (auth collection with Nebula integration).
 // This is an auth collection that extends PocketBase users with Nebula-specific fields.
 //
@@ -278,10 +574,16 @@ func (cm *Manager) createNetworksCollection() error {
 // NEBULA INTEGRATION:
 // - hostname: Nebula identity
 // - Generated keys: certificate, private_key
-// - Relations: network_id (foreign key)
+// - Relations: network_id (foreign key), template_id (optional config template)
 // - Generated: ca_certificate (denormalized), config_yaml (complete Nebula config)
-// - Lighthouse: is_lighthouse, public_host_port
-// - Firewall: firewall_outbound, firewall_inbound (host-specific rules)
+// - Inspection: fingerprint, issuer (derived from certificate)
+// - Lighthouse: is_lighthouse, public_host_port, public_endpoints
+// - Listen: listen_host, listen_port, advertise_addrs (all host types)
+// - Relay: is_relay (relays also require a public endpoint)
+// - DNS: serve_dns, dns_host, dns_port (lighthouses only)
+// - Firewall: firewall_outbound, firewall_inbound (host-specific rules), firewall_rulesets (shared rule sets)
+// - Lint: firewall_findings (problems found in the effective firewall)
+// - Config: config_overrides (merged over network overrides and generated config)
 //
 // FIREWALL RULES (HOST-BASED):
 // - Each host defines its own firewall rules
@@ -293,6 +595,7 @@ func (cm *Manager) createNetworksCollection() error {
 // - groups: JSON array of group names (embedded in certificate)
 // - validity_years: Certificate validity period
 // - expires_at: Certificate expiration timestamp
+// - key_generation: Incremented on every explicit key rotation
 //
 // TWO-PHASE CREATION:
 // Collection must be saved before adding relation fields due to PocketBase requirements.
@@ -302,10 +605,18 @@ func (cm *Manager) createNetworksCollection() error {
 // - error if collection creation fails
 func (cm *Manager) createHostsCollection() error {
 	// Check if collection already exists
-	_, err := cm.app.FindCollectionByNameOrId(cm.options.HostCollectionName)
+	existing, err := cm.app.FindCollectionByNameOrId(cm.options.HostCollectionName)
 	if err == nil {
-		// Collection already exists
-		return nil
+		// Collection already exists - only add fields introduced since it was created
+		templateField, err := cm.templateRelationField()
+		if err != nil {
+			return err
+		}
+		ruleSetField, err := cm.ruleSetRelationField()
+		if err != nil {
+			return err
+		}
+		return cm.ensureFields(existing, slices.Concat(certInfoFields(), hostEndpointFields(), hostListenFields(), hostRelayFields(), hostDNSFields(), hostKeyFields(), hostFirewallFindingFields(), firewallSettingFields(), configOverrideFields(), []core.Field{templateField, ruleSetField})...)
 	}
 
 	collection := core.NewAuthCollection(cm.options.HostCollectionName)
@@ -339,6 +650,10 @@ func (cm *Manager) createHostsCollection() error {
 		Name: "public_host_port",
 		Max:  100,
 	})
+	collection.Fields.Add(hostEndpointFields()...)
+	collection.Fields.Add(hostListenFields()...)
+	collection.Fields.Add(hostRelayFields()...)
+	collection.Fields.Add(hostDNSFields()...)
 
 	// Add certificate fields
 	collection.Fields.Add(&core.TextField{
@@ -357,6 +672,7 @@ func (cm *Manager) createHostsCollection() error {
 		Name: "config_yaml",
 		Max:  50000,
 	})
+	collection.Fields.Add(certInfoFields()...)
 
 	// Add host-specific firewall rules (Nebula native JSON format)
 	collection.Fields.Add(&core.JSONField{
@@ -367,6 +683,13 @@ func (cm *Manager) createHostsCollection() error {
 		Name:    "firewall_inbound",
 		MaxSize: 10000,
 	})
+	collection.Fields.Add(hostFirewallFindingFields()...)
+
+	// Add host-specific firewall actions and conntrack timeouts (win over network per key)
+	collection.Fields.Add(firewallSettingFields()...)
+
+	// Add host-specific Nebula config overrides (take precedence over network overrides)
+	collection.Fields.Add(configOverrideFields()...)
 
 	// Add validity fields
 	collection.Fields.Add(&core.NumberField{
@@ -378,6 +701,7 @@ func (cm *Manager) createHostsCollection() error {
 	collection.Fields.Add(&core.DateField{
 		Name: "expires_at",
 	})
+	collection.Fields.Add(hostKeyFields()...)
 
 	// Add management field
 	collection.Fields.Add(&core.BoolField{
@@ -403,6 +727,20 @@ func (cm *Manager) createHostsCollection() error {
 		CascadeDelete: false,
 	})
 
+	// Add optional relation to config template (wins over network template)
+	templateField, err := cm.templateRelationField()
+	if err != nil {
+		return err
+	}
+	collection.Fields.Add(templateField)
+
+	// Add optional relation to shared firewall rule sets
+	ruleSetField, err := cm.ruleSetRelationField()
+	if err != nil {
+		return err
+	}
+	collection.Fields.Add(ruleSetField)
+
 	// Create composite unique index on (network_id, overlay_ip) and unique index on hostname
 	collection.Indexes = types.JSONArray[string]{
 		"CREATE UNIQUE INDEX idx_host_network_ip ON " + cm.options.HostCollectionName + " (network_id, overlay_ip)",
@@ -411,3 +749,324 @@ func (cm *Manager) createHostsCollection() error {
 
 	return cm.app.Save(collection)
 }
+
+// certInfoFields returns the certificate inspection fields shared by CA and host collections.
+// Values are derived from the stored certificate by the sync layer.
+func certInfoFields() []core.Field {
+	return []core.Field{
+		&core.TextField{
+			Name: "fingerprint",
+			Max:  100,
+		},
+		&core.TextField{
+			Name: "issuer",
+			Max:  100,
+		},
+	}
+}
+
+// caConstraintFields returns the optional CA signing constraints.
+// Both are JSON string arrays embedded in the CA certificate at generation time.
+func caConstraintFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "allowed_networks",
+			MaxSize: 10000,
+		},
+		&core.JSONField{
+			Name:    "allowed_groups",
+			MaxSize: 10000,
+		},
+	}
+}
+
+// networkPKIFields returns the network-wide PKI fields.
+func networkPKIFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "blocklist",
+			MaxSize: 100000,
+		},
+	}
+}
+
+// networkFirewallDefaultFields returns the default firewall settings of a network.
+// Rules are JSON arrays of Nebula rule objects, only used in "custom" mode.
+func networkFirewallDefaultFields() []core.Field {
+	return []core.Field{
+		&core.SelectField{
+			Name:      "firewall_default_mode",
+			MaxSelect: 1,
+			Values: []string{
+				pbtypes.FirewallDefaultsRecommended,
+				pbtypes.FirewallDefaultsNone,
+				pbtypes.FirewallDefaultsCustom,
+			},
+		},
+		&core.JSONField{
+			Name:    "firewall_default_outbound",
+			MaxSize: 50000,
+		},
+		&core.JSONField{
+			Name:    "firewall_default_inbound",
+			MaxSize: 50000,
+		},
+	}
+}
+
+// hostEndpointFields returns the additional public endpoints of a host.
+// JSON string array rendered into static_host_map after public_host_port.
+func hostEndpointFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "public_endpoints",
+			MaxSize: 2000,
+		},
+	}
+}
+
+// hostListenFields returns the UDP listen settings honoured for every host type.
+// advertise_addrs is a JSON string array of IP:PORT reported to lighthouses.
+func hostListenFields() []core.Field {
+	return []core.Field{
+		&core.TextField{
+			Name: "listen_host",
+			Max:  100,
+		},
+		&core.NumberField{
+			Name:    "listen_port",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+			Max:     types.Pointer(65535.0),
+		},
+		&core.JSONField{
+			Name:    "advertise_addrs",
+			MaxSize: 2000,
+		},
+	}
+}
+
+// hostRelayFields returns the relay role flag for hosts.
+// Relays forward traffic for hosts that can't reach each other directly (e.g. symmetric NAT).
+func hostRelayFields() []core.Field {
+	return []core.Field{
+		&core.BoolField{
+			Name: "is_relay",
+		},
+	}
+}
+
+// hostDNSFields returns the lighthouse DNS server settings.
+// Lighthouses with serve_dns answer overlay queries for certificate hostnames.
+func hostDNSFields() []core.Field {
+	return []core.Field{
+		&core.BoolField{
+			Name: "serve_dns",
+		},
+		&core.TextField{
+			Name: "dns_host",
+			Max:  100,
+		},
+		&core.NumberField{
+			Name:    "dns_port",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+			Max:     types.Pointer(65535.0),
+		},
+	}
+}
+
+// hostFirewallFindingFields returns the firewall lint results stored on hosts.
+// Findings are recomputed by the sync layer whenever the host config is generated.
+func hostFirewallFindingFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "firewall_findings",
+			MaxSize: 50000,
+		},
+	}
+}
+
+// hostKeyFields returns the host key lifecycle fields.
+func hostKeyFields() []core.Field {
+	return []core.Field{
+		&core.NumberField{
+			Name:    "key_generation",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+		},
+	}
+}
+
+// queueRevisionFields returns the request counter of regeneration jobs.
+// Timestamps of two requests can be equal, the counter always moves.
+func queueRevisionFields() []core.Field {
+	return []core.Field{
+		&core.NumberField{
+			Name:    "revision",
+			OnlyInt: true,
+			Min:     types.Pointer(0.0),
+		},
+	}
+}
+
+// firewallSettingFields returns the firewall settings field shared by networks and hosts.
+// The JSON object holds actions, conntrack timeouts and default_local_cidr_any.
+func firewallSettingFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "firewall_settings",
+			MaxSize: 2000,
+		},
+	}
+}
+
+// configOverrideFields returns the config override field shared by networks and hosts.
+// The JSON object is merged over the generated Nebula config.
+func configOverrideFields() []core.Field {
+	return []core.Field{
+		&core.JSONField{
+			Name:    "config_overrides",
+			MaxSize: 20000,
+		},
+	}
+}
+
+// templateRelationField returns the optional template_id relation used by networks and hosts.
+// The templates collection must already exist.
+func (cm *Manager) templateRelationField() (core.Field, error) {
+	templatesCollection, err := cm.app.FindCollectionByNameOrId(cm.options.TemplateCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("templates collection not found: %w", err)
+	}
+
+	return &core.RelationField{
+		Name:          "template_id",
+		MaxSelect:     1,
+		CollectionId:  templatesCollection.Id,
+		CascadeDelete: false,
+	}, nil
+}
+
+// ruleSetRelationField returns the optional firewall_rulesets relation of hosts.
+// The rule sets collection must already exist.
+func (cm *Manager) ruleSetRelationField() (core.Field, error) {
+	ruleSetsCollection, err := cm.app.FindCollectionByNameOrId(cm.options.RuleSetCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("firewall rule sets collection not found: %w", err)
+	}
+
+	return &core.RelationField{
+		Name:          "firewall_rulesets",
+		MaxSelect:     50,
+		CollectionId:  ruleSetsCollection.Id,
+		CascadeDelete: false,
+	}, nil
+}
+
+// ensureFields adds any of the given fields missing from an existing collection.
+// This lets deployments created by older versions pick up new fields on upgrade.
+//
+// UPGRADE BEHAVIOR:
+// - Fields that already exist are left untouched (even if their options differ)
+// - Collection is only saved when at least one field was added
+//
+// PARAMETERS:
+//   - collection: Existing collection to upgrade
+//   - fields: Fields that should exist on the collection
+//
+// RETURNS:
+// - nil if nothing was missing or the collection was saved
+// - error if saving the collection fails
+func (cm *Manager) ensureFields(collection *core.Collection, fields ...core.Field) error {
+	added := false
+	for _, field := range fields {
+		if collection.Fields.GetByName(field.GetName()) != nil {
+			continue
+		}
+		collection.Fields.Add(field)
+		added = true
+	}
+
+	if !added {
+		return nil
+	}
+
+	return cm.app.Save(collection)
+}
+
+// createQueueCollection creates the regeneration jobs collection (admin only).
+// Each record is a host whose config regeneration is pending, so queued
+// regenerations survive restarts.
+//
+// SCHEMA:
+// - host: Relation to the host (unique, jobs are deleted with their host)
+// - requested: When the latest regeneration was requested
+// - revision: Incremented by every request, so a worker can tell the job was requeued
+// - Metadata: created, updated timestamps
+//
+// MULTI-TENANT:
+// Jobs relate to one host collection, so tenants can't share a queue collection.
+// An existing queue collection of another tenant's hosts is rejected.
+//
+// RETURNS:
+// - nil if collection created successfully or already exists
+// - error if collection creation fails or the collection belongs to another tenant
+func (cm *Manager) createQueueCollection() error {
+	hostsCollection, err := cm.app.FindCollectionByNameOrId(cm.options.HostCollectionName)
+	if err != nil {
+		return fmt.Errorf("hosts collection not found: %w", err)
+	}
+
+	// Check if collection already exists
+	existing, err := cm.app.FindCollectionByNameOrId(cm.options.QueueCollectionName)
+	if err == nil {
+		// Collection already exists
+		host, ok := existing.Fields.GetByName("host").(*core.RelationField)
+		if !ok || host.CollectionId != hostsCollection.Id {
+			return fmt.Errorf("queue collection %s holds jobs of another host collection, set a QueueCollectionName for %s",
+				cm.options.QueueCollectionName, cm.options.HostCollectionName)
+		}
+		return cm.ensureFields(existing, queueRevisionFields()...)
+	}
+
+	collection := core.NewBaseCollection(cm.options.QueueCollectionName)
+
+	// Admin only access - no public access
+	collection.ListRule = nil
+	collection.ViewRule = nil
+	collection.CreateRule = nil
+	collection.UpdateRule = nil
+	collection.DeleteRule = nil
+
+	collection.Fields.Add(&core.RelationField{
+		Name:          "host",
+		Required:      true,
+		MaxSelect:     1,
+		CollectionId:  hostsCollection.Id,
+		CascadeDelete: true,
+	})
+	collection.Fields.Add(&core.DateField{
+		Name: "requested",
+	})
+	for _, field := range queueRevisionFields() {
+		collection.Fields.Add(field)
+	}
+
+	// Add timestamps
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "created",
+		OnCreate: true,
+	})
+	collection.Fields.Add(&core.AutodateField{
+		Name:     "updated",
+		OnCreate: true,
+		OnUpdate: true,
+	})
+
+	collection.Indexes = types.JSONArray[string]{
+		"CREATE UNIQUE INDEX idx_" + cm.options.QueueCollectionName + "_host ON " + cm.options.QueueCollectionName + " (host)",
+	}
+
+	return cm.app.Save(collection)
+}
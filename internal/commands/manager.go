@@ -0,0 +1,311 @@
+// Package commands provides the pb-nebula PocketBase CLI commands
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	gosync "sync"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+	"github.com/skeeeon/pb-nebula/internal/types"
+	"github.com/skeeeon/pb-nebula/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// Manager registers pb-nebula commands on PocketBase's CLI.
+//
+// COMMAND PREFIX:
+// All commands live under the "nebula" command (e.g. `./app nebula doctor`).
+//
+// LIFECYCLE:
+// Commands are registered before PocketBase parses the command line, so they
+// show up in --help. PocketBase bootstraps the app before running a command,
+// which creates the collections and registers the sync hooks, so records saved
+// by a command are handled exactly like API saves.
+//
+// MULTI-TENANT:
+// Each Setup call is a tenant, named by its host collection. The commands are
+// registered once per app and select the tenant with --tenant.
+type Manager struct {
+	app      *pocketbase.PocketBase // PocketBase application instance
+	tenants  []tenant               // Tenants the commands can operate on
+	selected string                 // Host collection chosen with --tenant
+}
+
+// tenant holds the options of one Setup call.
+type tenant struct {
+	options types.Options // Configuration options
+	logger  *utils.Logger // Logger for consistent output
+}
+
+// registered maps each app to the commands manager that registered its
+// commands, so later Setup calls add their tenant instead of registering again.
+var (
+	registeredMu gosync.Mutex
+	registered   = map[*pocketbase.PocketBase]*Manager{}
+)
+
+// NewManager creates a new commands manager.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance
+//   - options: Configuration options
+//   - logger: Logger instance
+//
+// RETURNS:
+// - Manager instance ready for command setup
+func NewManager(app *pocketbase.PocketBase, options types.Options, logger *utils.Logger) *Manager {
+	return &Manager{
+		app:     app,
+		tenants: []tenant{{options: options, logger: logger}},
+	}
+}
+
+// SetupCommands registers the pb-nebula commands on PocketBase's root command.
+// With several Setup calls (multi-tenant), the first registers the commands and
+// later calls add their tenant to them.
+//
+// COMMANDS:
+// - nebula doctor [--repair]: Audit database consistency, optionally regenerate broken hosts
+// - nebula regenerate (--host ID | --network ID | --all) [--resign] [--dry-run]: Force-regenerate hosts
+// - --tenant COLLECTION (all commands): Host collection of the tenant, required with several tenants
+//
+// RETURNS:
+// - nil on successful command registration
+// - error if command setup fails
+func (cm *Manager) SetupCommands() error {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	if first, ok := registered[cm.app]; ok {
+		first.addTenants(cm.tenants)
+		return nil
+	}
+	registered[cm.app] = cm
+
+	nebulaCmd := &cobra.Command{
+		Use:   "nebula",
+		Short: "Nebula certificate and config management",
+	}
+	nebulaCmd.PersistentFlags().StringVar(&cm.selected, "tenant", "",
+		"host collection of the tenant to operate on (required with several tenants)")
+	nebulaCmd.AddCommand(cm.doctorCommand())
+	nebulaCmd.AddCommand(cm.regenerateCommand())
+
+	cm.app.RootCmd.AddCommand(nebulaCmd)
+	return nil
+}
+
+// doctorCommand builds `nebula doctor`.
+// Exits with an error while problems remain, so it can gate scripts and CI.
+func (cm *Manager) doctorCommand() *cobra.Command {
+	var repair bool
+
+	cmd := &cobra.Command{
+		Use:          "doctor",
+		Short:        "Audit Nebula records for inconsistencies",
+		Long:         "Audits hosts, networks and CAs for inconsistencies (IPs, lighthouses, stale configs, certificates) and optionally repairs them by regenerating the affected hosts.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			syncManager, err := cm.syncManager()
+			if err != nil {
+				return err
+			}
+
+			report, err := syncManager.Doctor(repair)
+			if err != nil {
+				return err
+			}
+
+			printDoctorReport(cmd.OutOrStdout(), report, repair)
+
+			if len(report.Remaining) > 0 {
+				return fmt.Errorf("%d problems found", len(report.Remaining))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&repair, "repair", false, "regenerate hosts with repairable problems")
+
+	return cmd
+}
+
+// regenerateCommand builds `nebula regenerate`.
+// The scope must be explicit - forgetting --host shouldn't regenerate every host.
+func (cm *Manager) regenerateCommand() *cobra.Command {
+	var request sync.RegenerateRequest
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:          "regenerate",
+		Short:        "Force-regenerate host configs or certificates",
+		Long:         "Regenerates the configs (or re-signs the certificates with --resign) of a host, a network or every host, e.g. after changing generator defaults or upgrading pb-nebula.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			scopes := 0
+			for _, set := range []bool{request.HostID != "", request.NetworkID != "", all} {
+				if set {
+					scopes++
+				}
+			}
+			if scopes != 1 {
+				return fmt.Errorf("select exactly one of --host, --network or --all")
+			}
+
+			syncManager, err := cm.syncManager()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			report, err := syncManager.Regenerate(request, func(done, total int, result sync.RegenerateResult) {
+				fmt.Fprintf(out, "[%d/%d] %s (%s): %s\n", done, total, result.Hostname, result.HostID, regenerateOutcome(result))
+			})
+			if report == nil {
+				return err
+			}
+
+			printRegenerateReport(out, report)
+
+			if report.Failed > 0 {
+				return fmt.Errorf("%d hosts failed", report.Failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&request.HostID, "host", "", "regenerate a single host (record ID)")
+	cmd.Flags().StringVar(&request.NetworkID, "network", "", "regenerate every host in a network (record ID)")
+	cmd.Flags().BoolVar(&all, "all", false, "regenerate every host")
+	cmd.Flags().BoolVar(&request.Resign, "resign", false, "re-sign certificates too (keeps host keys when possible)")
+	cmd.Flags().BoolVar(&request.DryRun, "dry-run", false, "generate and validate without saving")
+
+	return cmd
+}
+
+// addTenants adds tenants of a later Setup call, skipping host collections
+// that are already registered.
+func (cm *Manager) addTenants(tenants []tenant) {
+	for _, added := range tenants {
+		if !cm.hasTenant(added.options.HostCollectionName) {
+			cm.tenants = append(cm.tenants, added)
+		}
+	}
+}
+
+// hasTenant reports whether a tenant uses the given host collection.
+func (cm *Manager) hasTenant(hostCollection string) bool {
+	for _, t := range cm.tenants {
+		if t.options.HostCollectionName == hostCollection {
+			return true
+		}
+	}
+	return false
+}
+
+// tenant resolves the tenant selected with --tenant.
+// Without the flag, the only tenant is used; several tenants require the flag.
+func (cm *Manager) tenant() (tenant, error) {
+	names := make([]string, 0, len(cm.tenants))
+	for _, t := range cm.tenants {
+		if t.options.HostCollectionName == cm.selected || (cm.selected == "" && len(cm.tenants) == 1) {
+			return t, nil
+		}
+		names = append(names, t.options.HostCollectionName)
+	}
+
+	if cm.selected == "" {
+		return tenant{}, fmt.Errorf("several tenants are set up, select one with --tenant (%s)", strings.Join(names, ", "))
+	}
+	return tenant{}, fmt.Errorf("unknown tenant %q, expected one of: %s", cm.selected, strings.Join(names, ", "))
+}
+
+// syncManager creates a sync manager for the selected tenant (the app is bootstrapped by then).
+func (cm *Manager) syncManager() (*sync.Manager, error) {
+	t, err := cm.tenant()
+	if err != nil {
+		return nil, err
+	}
+
+	return sync.NewStandaloneManager(cm.app, t.options, t.logger), nil
+}
+
+// regenerateOutcome describes the result of a single host for progress output.
+func regenerateOutcome(result sync.RegenerateResult) string {
+	switch {
+	case result.Error != "":
+		return "FAILED: " + result.Error
+	case result.Changed:
+		return "changed"
+	default:
+		return "unchanged"
+	}
+}
+
+// printRegenerateReport writes the summary and failures of a bulk regeneration.
+func printRegenerateReport(out io.Writer, report *sync.RegenerateReport) {
+	prefix := ""
+	if report.DryRun {
+		prefix = "Dry run: "
+	}
+	fmt.Fprintf(out, "\n%s%d hosts, %d succeeded (%d changed), %d failed.\n",
+		prefix, report.Total, report.Succeeded, report.Changed, report.Failed)
+
+	if report.Failed == 0 {
+		return
+	}
+	fmt.Fprintln(out, "Failures:")
+	for _, result := range report.Hosts {
+		if result.Error != "" {
+			fmt.Fprintf(out, "  %s (%s): %s\n", result.Hostname, result.HostID, result.Error)
+		}
+	}
+}
+
+// printDoctorReport writes a human-readable doctor report.
+func printDoctorReport(out io.Writer, report *sync.DoctorReport, repaired bool) {
+	if len(report.Findings) == 0 {
+		fmt.Fprintln(out, "No problems found.")
+		return
+	}
+
+	fmt.Fprintf(out, "Found %d problems:\n", len(report.Findings))
+	printFindings(out, report.Findings)
+
+	if !repaired {
+		repairable := 0
+		for _, finding := range report.Findings {
+			if finding.Repair != "" {
+				repairable++
+			}
+		}
+		if repairable > 0 {
+			fmt.Fprintf(out, "\n%d problems can be repaired with --repair.\n", repairable)
+		}
+		return
+	}
+
+	fmt.Fprintf(out, "\nRepaired %d hosts.\n", len(report.Repaired))
+	for _, message := range report.RepairErrors {
+		fmt.Fprintf(out, "  repair failed: %s\n", message)
+	}
+
+	if len(report.Remaining) > 0 {
+		fmt.Fprintf(out, "\n%d problems remain:\n", len(report.Remaining))
+		printFindings(out, report.Remaining)
+	}
+}
+
+// printFindings writes one line per finding.
+func printFindings(out io.Writer, findings []sync.DoctorFinding) {
+	for _, finding := range findings {
+		line := fmt.Sprintf("  [%s] %s %s (%s): %s", finding.Check, finding.Collection, finding.Name, finding.RecordID, finding.Problem)
+		if finding.Repair != "" {
+			line += fmt.Sprintf(" (repair: %s)", finding.Repair)
+		}
+		fmt.Fprintln(out, line)
+	}
+}
@@ -3,11 +3,14 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/skeeeon/pb-nebula/internal/firewall"
 	"github.com/skeeeon/pb-nebula/internal/types"
 )
 
@@ -32,83 +35,309 @@ func NewGenerator() *Generator {
 	return &Generator{}
 }
 
+// HostConfigParams contains all inputs needed to generate a host config.
+type HostConfigParams struct {
+	Host        *types.HostRecord             // Host record with certificates and firewall rules
+	Network     *types.NetworkRecord          // Network record the host belongs to
+	Lighthouses []types.LighthouseInfo        // Lighthouse hosts in this network
+	Relays      []string                      // Overlay IPs of relay hosts in this network (excluding this host)
+	Policies    []types.FirewallPolicyRecord  // Firewall policies of this network
+	RuleSets    []types.FirewallRuleSetRecord // Firewall rule sets referenced by the host
+	Defaults    types.FirewallDefaults        // Rules for empty firewall directions (zero value = recommended)
+	Template    string                        // Optional config template (empty = built-in layout)
+}
+
+// EffectiveFirewall assembles the firewall rules rendered into a host's config.
+// This is exactly what Nebula enforces on the host (before config overrides).
+//
+// RULE ORDER:
+// 1. Network policies matching the host's groups
+// 2. Rule sets referenced by the host (in reference order)
+// 3. The host's inline rules
+// 4. Defaults, only for a direction left without rules (see firewall.DefaultRules):
+//   - recommended: Outbound allow all, inbound ICMP from any (essential for troubleshooting)
+//   - none: Nothing - the direction stays empty and Nebula denies it
+//   - custom: The network's or Options' own default rules
+//
+// PARAMETERS:
+//   - params: Host, policies, rule sets and defaults (other fields are ignored)
+//
+// RETURNS:
+// - outbound, inbound: Effective rules in Nebula's native format
+// - error if the host rules are invalid or a policy/rule set is malformed
+func (g *Generator) EffectiveFirewall(params HostConfigParams) (outbound, inbound []map[string]interface{}, err error) {
+	host := params.Host
+
+	// Parse host-specific firewall rules
+	outbound, inbound, err = host.GetFirewallRules()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse firewall rules: %w", err)
+	}
+	if err := firewall.ValidateRules("outbound", outbound); err != nil {
+		return nil, nil, err
+	}
+	if err := firewall.ValidateRules("inbound", inbound); err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := host.GetGroups()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse groups: %w", err)
+	}
+
+	// Compile network policies matching the host's groups and referenced rule sets
+	// in front of its own rules (policies, then rule sets, then inline rules)
+	policyOutbound, policyInbound, err := firewall.CompilePolicies(params.Policies, groups)
+	if err != nil {
+		return nil, nil, err
+	}
+	ruleSetOutbound, ruleSetInbound, err := firewall.CompileRuleSets(params.RuleSets)
+	if err != nil {
+		return nil, nil, err
+	}
+	outbound = slices.Concat(policyOutbound, ruleSetOutbound, outbound)
+	inbound = slices.Concat(policyInbound, ruleSetInbound, inbound)
+
+	// If no rules specified, use the configured defaults
+	defaultOutbound, defaultInbound, err := firewall.DefaultRules(params.Defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(outbound) == 0 {
+		outbound = defaultOutbound
+	}
+	if len(inbound) == 0 {
+		inbound = defaultInbound
+	}
+
+	return outbound, inbound, nil
+}
+
+// firewallSettings merges the host's firewall settings over the network's.
+func (g *Generator) firewallSettings(host *types.HostRecord, network *types.NetworkRecord) (types.FirewallSettings, error) {
+	networkSettings, err := network.GetFirewallSettings()
+	if err != nil {
+		return types.FirewallSettings{}, fmt.Errorf("failed to parse network firewall settings: %w", err)
+	}
+	hostSettings, err := host.GetFirewallSettings()
+	if err != nil {
+		return types.FirewallSettings{}, fmt.Errorf("failed to parse host firewall settings: %w", err)
+	}
+
+	settings := firewall.MergeSettings(networkSettings, hostSettings)
+	if err := firewall.ValidateSettings(settings); err != nil {
+		return types.FirewallSettings{}, err
+	}
+	return settings, nil
+}
+
 // GenerateHostConfig generates a complete Nebula YAML configuration for a host.
 // The generated config includes PKI, lighthouse discovery, host-based firewall rules, and all
 // necessary Nebula settings with recommended defaults.
 //
 // LIGHTHOUSE BEHAVIOR:
-// - Lighthouse hosts: am_lighthouse=true, no static_host_map
+// - Lighthouse hosts: am_lighthouse=true, no static_host_map, optional DNS server
 // - Regular hosts: am_lighthouse=false, static_host_map with lighthouse IPs
 //
+// OVERLAY DNS:
+// Lighthouses with serve_dns answer queries for certificate hostnames. Regular host
+// configs start with a comment listing these resolvers (Nebula doesn't configure them).
+//
+// RELAY BEHAVIOR:
+// - Relay hosts: relay.am_relay=true
+// - Other hosts: relay.relays with the network's relays, use_relays=true
+// - No relay section at all when the network has no relays
+//
 // FIREWALL RULES (HOST-BASED):
 // Each host defines its own firewall rules stored in the host record.
 // Rules use Nebula's native format and reference GROUPS from certificates.
-// Default behavior follows Nebula recommendations:
-// - Outbound: Allow all
-// - Inbound: Allow ICMP from any (essential for troubleshooting)
+// Network firewall policies and rule sets are compiled in front of them, and
+// configurable defaults fill empty directions (see EffectiveFirewall).
+//
+// FIREWALL SETTINGS:
+// Actions (drop/reject), conntrack timeouts and default_local_cidr_any come from
+// the network's and host's firewall_settings, host winning per key. Unset keys are
+// left out so Nebula applies its own defaults.
+//
+// CONFIG TEMPLATES:
+// If a template is provided it is rendered instead of the built-in layout.
+// See RenderTemplate for the data available to templates.
+//
+// CONFIG OVERRIDES:
+// Network and host config_overrides are merged over the generated (or rendered) config,
+// with host overriding network overriding built-in defaults.
+// See mergeConfig for merge semantics.
+//
+// CERTIFICATE BLOCKLIST:
+// Fingerprints blocklisted on the network (e.g., after key rotation) are rendered
+// into pki.blocklist so every host rejects the retired certificates.
 //
 // PARAMETERS:
-//   - host: Host record with certificates and firewall rules
-//   - lighthouses: List of lighthouse hosts in this network
+//   - params: Host, network, lighthouses and optional template
 //
 // RETURNS:
 // - string: Complete Nebula YAML configuration ready to use
 // - error if config generation fails
 //
 // SIDE EFFECTS: None (pure generation)
-func (g *Generator) GenerateHostConfig(host *types.HostRecord, lighthouses []types.LighthouseInfo) (string, error) {
-	// Parse host-specific firewall rules
-	outbound, inbound, err := host.GetFirewallRules()
+func (g *Generator) GenerateHostConfig(params HostConfigParams) (string, error) {
+	host, network, lighthouses := params.Host, params.Network, params.Lighthouses
+
+	// Assemble effective firewall rules (policies, rule sets, inline rules, defaults)
+	outbound, inbound, err := g.EffectiveFirewall(params)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse firewall rules: %w", err)
+		return "", err
 	}
 
-	// If no rules specified, use Nebula recommended defaults
-	if len(outbound) == 0 {
-		outbound = []map[string]interface{}{
-			{"port": "any", "proto": "any", "host": "any"},
+	firewallSettings, err := g.firewallSettings(host, network)
+	if err != nil {
+		return "", err
+	}
+
+	groups, err := host.GetGroups()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse groups: %w", err)
+	}
+
+	// Parse network certificate blocklist
+	blocklist, err := network.GetBlocklist()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse certificate blocklist: %w", err)
+	}
+
+	// Build PKI section, only including the blocklist when there is something to block
+	pki := map[string]interface{}{
+		"ca":   host.CACertificate,
+		"cert": host.Certificate,
+		"key":  host.PrivateKey,
+	}
+	if len(blocklist) > 0 {
+		pki["blocklist"] = blocklist
+	}
+
+	staticHostMap, err := g.buildStaticHostMap(lighthouses, host.IsLighthouse)
+	if err != nil {
+		return "", err
+	}
+
+	// Listen port: explicit listen_port, else the primary public endpoint's port
+	endpoints, err := host.GetPublicEndpoints()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public endpoints: %w", err)
+	}
+	primaryEndpoint := ""
+	if len(endpoints) > 0 {
+		primaryEndpoint = endpoints[0]
+	}
+	listenPort := host.ListenPort
+	if listenPort == 0 {
+		listenPort, err = g.extractPort(primaryEndpoint, host.IsLighthouse || host.IsRelay)
+		if err != nil {
+			return "", err
 		}
 	}
-	if len(inbound) == 0 {
-		// Nebula recommended default: Allow ICMP for troubleshooting
-		inbound = []map[string]interface{}{
-			{"port": "any", "proto": "icmp", "host": "any"},
-		}
-	}
-
-	// Build config structure
-	config := map[string]interface{}{
-		"pki": map[string]interface{}{
-			"ca":   host.CACertificate,
-			"cert": host.Certificate,
-			"key":  host.PrivateKey,
-		},
-		"static_host_map": g.buildStaticHostMap(lighthouses, host.IsLighthouse),
-		"lighthouse":      g.buildLighthouseConfig(lighthouses, host.IsLighthouse),
-		"listen": map[string]interface{}{
-			"host": "0.0.0.0",
-			"port": g.extractPort(host.PublicHostPort, host.IsLighthouse),
-		},
-		"punchy": map[string]interface{}{
-			"punch":   true,
-			"respond": true,
-		},
-		"tun": map[string]interface{}{
-			"disabled":              false,
-			"dev":                   "nebula1",
-			"drop_local_broadcast":  false,
-			"drop_multicast":        false,
-			"tx_queue":              500,
-			"mtu":                   1300,
-		},
-		"logging": map[string]interface{}{
-			"level":  "info",
-			"format": "text",
-		},
-		"firewall": map[string]interface{}{
-			"outbound": outbound,
-			"inbound":  inbound,
-		},
+	listenHost := host.ListenHost
+	if listenHost == "" {
+		listenHost = defaultListenHost
+	}
+
+	advertiseAddrs, err := g.buildAdvertiseAddrs(host)
+	if err != nil {
+		return "", err
+	}
+	relay := g.buildRelayConfig(params.Relays, host.IsRelay)
+	dnsResolvers := g.dnsResolvers(lighthouses)
+
+	var config map[string]interface{}
+	if params.Template != "" {
+		// Render the assigned template
+		config, err = g.RenderTemplate(params.Template, TemplateData{
+			Hostname:       host.Hostname,
+			OverlayIP:      host.OverlayIP,
+			Groups:         groups,
+			IsLighthouse:   host.IsLighthouse,
+			IsRelay:        host.IsRelay,
+			ListenHost:     listenHost,
+			ListenPort:     listenPort,
+			AdvertiseAddrs: advertiseAddrs,
+			Network:        TemplateNetwork{Name: network.Name, CIDR: network.CIDRRange},
+			PKI:            TemplatePKI{CA: host.CACertificate, Cert: host.Certificate, Key: host.PrivateKey, Blocklist: blocklist},
+			Lighthouses:    lighthouses,
+			Relays:         params.Relays,
+			DNSResolvers:   dnsResolvers,
+			StaticHostMap:  staticHostMap,
+			Firewall:       TemplateFirewall{Outbound: outbound, Inbound: inbound, Settings: firewall.RenderSettings(firewallSettings)},
+		})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// Build config structure
+		config = map[string]interface{}{
+			"pki":             pki,
+			"static_host_map": staticHostMap,
+			"lighthouse":      g.buildLighthouseConfig(lighthouses, host, advertiseAddrs),
+			"listen": map[string]interface{}{
+				"host": listenHost,
+				"port": listenPort,
+			},
+			"punchy": map[string]interface{}{
+				"punch":   true,
+				"respond": true,
+			},
+			"tun": map[string]interface{}{
+				"disabled":             false,
+				"dev":                  "nebula1",
+				"drop_local_broadcast": false,
+				"drop_multicast":       false,
+				"tx_queue":             500,
+				"mtu":                  1300,
+			},
+			"logging": map[string]interface{}{
+				"level":  "info",
+				"format": "text",
+			},
+		}
+
+		firewallSection := firewall.RenderSettings(firewallSettings)
+		firewallSection["outbound"] = outbound
+		firewallSection["inbound"] = inbound
+		config["firewall"] = firewallSection
+
+		if relay != nil {
+			config["relay"] = relay
+		}
+	}
+
+	configYAML, err := g.finalizeConfig(config, host, network)
+	if err != nil {
+		return "", err
+	}
+
+	// Lighthouses answer DNS themselves, other hosts get the resolvers documented
+	if !host.IsLighthouse && len(dnsResolvers) > 0 {
+		configYAML = g.dnsResolverComment(dnsResolvers) + configYAML
+	}
+
+	return configYAML, nil
+}
+
+// finalizeConfig applies network and host overrides and marshals the config to YAML.
+func (g *Generator) finalizeConfig(config map[string]interface{}, host *types.HostRecord, network *types.NetworkRecord) (string, error) {
+	// Apply overrides: host overrides network overrides built-in defaults
+	networkOverrides, err := network.GetConfigOverrides()
+	if err != nil {
+		return "", fmt.Errorf("invalid network config overrides: %w", err)
+	}
+	hostOverrides, err := host.GetConfigOverrides()
+	if err != nil {
+		return "", fmt.Errorf("invalid host config overrides: %w", err)
+	}
+	for _, overrides := range []map[string]interface{}{networkOverrides, hostOverrides} {
+		if err := g.ValidateOverrides(overrides); err != nil {
+			return "", err
+		}
+		mergeConfig(config, overrides)
 	}
 
 	// Marshal to YAML
@@ -126,44 +355,82 @@ func (g *Generator) GenerateHostConfig(host *types.HostRecord, lighthouses []typ
 // LIGHTHOUSE LOGIC:
 // - Lighthouse hosts don't need static_host_map (they are the discovery points)
 // - Regular hosts need static_host_map entries for all lighthouses
+// - Every public endpoint is listed so Nebula can fall back when one is unreachable
 //
 // PARAMETERS:
 //   - lighthouses: List of lighthouses in the network
 //   - isLighthouse: True if this host is a lighthouse
 //
 // RETURNS:
-// - map[string][]string: Static host map (overlay IP -> public endpoints)
+// - map[string][]string: Static host map (overlay IP -> canonical public endpoints)
 // - nil if this host is a lighthouse
-func (g *Generator) buildStaticHostMap(lighthouses []types.LighthouseInfo, isLighthouse bool) map[string][]string {
+// - error if a lighthouse endpoint is malformed (wraps types.ErrInvalidEndpoint and friends)
+func (g *Generator) buildStaticHostMap(lighthouses []types.LighthouseInfo, isLighthouse bool) (map[string][]string, error) {
 	if isLighthouse {
-		return nil // Lighthouses don't need static host map
+		return nil, nil // Lighthouses don't need static host map
 	}
 
 	hostMap := make(map[string][]string)
 	for _, lh := range lighthouses {
-		hostMap[lh.OverlayIP] = []string{lh.PublicHostPort}
+		endpoints := lh.PublicEndpoints
+		if len(endpoints) == 0 && lh.PublicHostPort != "" {
+			endpoints = []string{lh.PublicHostPort}
+		}
+
+		// Render endpoints in canonical form (e.g., bracketed IPv6)
+		canonical := make([]string, len(endpoints))
+		for i, raw := range endpoints {
+			endpoint, err := types.ParseEndpoint(raw)
+			if err != nil {
+				return nil, fmt.Errorf("lighthouse %s: %w", lh.OverlayIP, err)
+			}
+			canonical[i] = endpoint.String()
+		}
+		hostMap[lh.OverlayIP] = canonical
 	}
-	return hostMap
+	return hostMap, nil
 }
 
 // buildLighthouseConfig creates the lighthouse section for discovery configuration.
 // This configures whether this host is a lighthouse and which lighthouses to use.
 //
 // LIGHTHOUSE CONFIGURATION:
-// - Lighthouse hosts: am_lighthouse=true
+// - Lighthouse hosts: am_lighthouse=true, plus serve_dns and dns listen address if enabled
 // - Regular hosts: am_lighthouse=false, list of lighthouse overlay IPs, interval=60
 //
+// ADVERTISED ADDRESSES:
+// Any host type may set advertise_addrs (e.g., behind a port-forwarded firewall).
+//
 // PARAMETERS:
 //   - lighthouses: List of lighthouses in the network
-//   - isLighthouse: True if this host is a lighthouse
+//   - host: Host the config is generated for
+//   - advertiseAddrs: Canonical addresses for lighthouse.advertise_addrs (may be empty)
 //
 // RETURNS:
 // - map[string]interface{}: Lighthouse configuration section
-func (g *Generator) buildLighthouseConfig(lighthouses []types.LighthouseInfo, isLighthouse bool) map[string]interface{} {
-	if isLighthouse {
-		return map[string]interface{}{
+func (g *Generator) buildLighthouseConfig(lighthouses []types.LighthouseInfo, host *types.HostRecord, advertiseAddrs []string) map[string]interface{} {
+	section := g.lighthouseRoleConfig(lighthouses, host)
+	if len(advertiseAddrs) > 0 {
+		section["advertise_addrs"] = advertiseAddrs
+	}
+	return section
+}
+
+// lighthouseRoleConfig creates the role-specific part of the lighthouse section.
+func (g *Generator) lighthouseRoleConfig(lighthouses []types.LighthouseInfo, host *types.HostRecord) map[string]interface{} {
+	if host.IsLighthouse {
+		section := map[string]interface{}{
 			"am_lighthouse": true,
 		}
+		if host.ServeDNS {
+			dnsHost, dnsPort := dnsListenAddress(host.OverlayIP, host.DNSHost, host.DNSPort)
+			section["serve_dns"] = true
+			section["dns"] = map[string]interface{}{
+				"host": dnsHost,
+				"port": dnsPort,
+			}
+		}
+		return section
 	}
 
 	// Extract lighthouse overlay IPs
@@ -179,36 +446,190 @@ func (g *Generator) buildLighthouseConfig(lighthouses []types.LighthouseInfo, is
 	}
 }
 
-// extractPort extracts the port number from a "IP:PORT" string.
-// Returns 0 if the host is not a lighthouse (no listening needed).
+// defaultListenHost is the UDP listen address when a host doesn't set listen_host.
+const defaultListenHost = "0.0.0.0"
+
+// buildAdvertiseAddrs parses a host's advertised addresses into canonical form.
 //
-// LIGHTHOUSE PORT:
-// Lighthouses listen on a specific port for discovery requests.
-// Regular hosts typically use port 0 (random ephemeral port).
+// RETURNS:
+// - []string: Canonical IP:PORT addresses (empty if none configured)
+// - error if an address is malformed (wraps types.ErrInvalidEndpoint and friends)
+func (g *Generator) buildAdvertiseAddrs(host *types.HostRecord) ([]string, error) {
+	raw, err := host.GetAdvertiseAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse advertise_addrs: %w", err)
+	}
+
+	addrs := make([]string, len(raw))
+	for i, addr := range raw {
+		endpoint, err := types.ParseAdvertiseAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("advertise_addrs: %w", err)
+		}
+		addrs[i] = endpoint.String()
+	}
+	return addrs, nil
+}
+
+// defaultDNSPort is the port lighthouses serve DNS on when none is configured.
+const defaultDNSPort = 53
+
+// dnsListenAddress resolves a lighthouse's DNS listen address, applying defaults.
+// The DNS server listens on the overlay IP unless a host is configured, so it
+// isn't exposed on the lighthouse's public interfaces.
+func dnsListenAddress(overlayIP, dnsHost string, dnsPort int) (string, int) {
+	if dnsHost == "" {
+		dnsHost = overlayIP
+	}
+	if dnsPort == 0 {
+		dnsPort = defaultDNSPort
+	}
+	return dnsHost, dnsPort
+}
+
+// dnsResolvers returns the overlay DNS resolvers (host:port) served by lighthouses.
+// Lighthouses listening on an unspecified address (0.0.0.0 or ::) are reached via their overlay IP.
 //
 // PARAMETERS:
-//   - publicHostPort: Public IP:PORT string (e.g., "1.2.3.4:4242")
-//   - isLighthouse: True if this host is a lighthouse
+//   - lighthouses: List of lighthouses in the network
 //
 // RETURNS:
-// - int: Port number, or 0 if not a lighthouse
-func (g *Generator) extractPort(publicHostPort string, isLighthouse bool) int {
-	if !isLighthouse || publicHostPort == "" {
-		return 0
+// - []string: Resolver addresses (empty if no lighthouse serves DNS)
+func (g *Generator) dnsResolvers(lighthouses []types.LighthouseInfo) []string {
+	resolvers := []string{}
+	for _, lh := range lighthouses {
+		if !lh.ServeDNS {
+			continue
+		}
+		dnsHost, dnsPort := dnsListenAddress(lh.OverlayIP, lh.DNSHost, lh.DNSPort)
+		if ip := net.ParseIP(dnsHost); ip != nil && ip.IsUnspecified() {
+			dnsHost = lh.OverlayIP
+		}
+		resolvers = append(resolvers, net.JoinHostPort(dnsHost, strconv.Itoa(dnsPort)))
 	}
+	return resolvers
+}
 
-	// Split on last colon to handle IPv6 addresses
-	parts := strings.Split(publicHostPort, ":")
-	if len(parts) < 2 {
-		return 0
+// dnsResolverComment renders the YAML comment header documenting the overlay DNS resolvers.
+// Nebula doesn't configure the system resolver, so this tells operators what to point it at.
+func (g *Generator) dnsResolverComment(resolvers []string) string {
+	var b strings.Builder
+	b.WriteString("# Overlay DNS: lighthouses resolve host names on this network.\n")
+	b.WriteString("# Point your resolver (e.g., systemd-resolved, /etc/resolv.conf) at:\n")
+	for _, resolver := range resolvers {
+		b.WriteString("#   " + resolver + "\n")
 	}
+	return b.String()
+}
 
-	// Get last part (port)
-	portStr := parts[len(parts)-1]
-	port, err := strconv.Atoi(portStr)
+// buildRelayConfig creates the relay section for relayed connectivity.
+// Relays let hosts that can't punch through NAT (e.g., symmetric NAT) still reach each other.
+//
+// RELAY CONFIGURATION:
+// - Relay hosts: am_relay=true
+// - Other hosts: am_relay=false, use_relays=true, list of relay overlay IPs
+//
+// PARAMETERS:
+//   - relays: Overlay IPs of relays in the network
+//   - isRelay: True if this host is a relay
+//
+// RETURNS:
+// - map[string]interface{}: Relay configuration section
+// - nil if this host is not a relay and the network has no relays
+func (g *Generator) buildRelayConfig(relays []string, isRelay bool) map[string]interface{} {
+	if isRelay {
+		return map[string]interface{}{
+			"am_relay": true,
+		}
+	}
+
+	if len(relays) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"am_relay":   false,
+		"use_relays": true,
+		"relays":     relays,
+	}
+}
+
+// extractPort extracts the port number from a public endpoint.
+// Returns 0 if the host doesn't need a fixed listen port.
+//
+// FIXED PORT:
+// Lighthouses listen on a specific port for discovery requests and relays
+// on a specific port for relayed traffic.
+// Regular hosts typically use port 0 (random ephemeral port).
+// An explicit listen_port on the host takes precedence over this.
+//
+// PARAMETERS:
+//   - publicHostPort: Public endpoint (e.g., "1.2.3.4:4242", "[2001:db8::1]:4242")
+//   - fixedPort: True if this host is a lighthouse or relay
+//
+// RETURNS:
+// - int: Port number, or 0 if no fixed port is needed
+// - error if the endpoint is malformed (wraps types.ErrInvalidEndpoint and friends)
+func (g *Generator) extractPort(publicHostPort string, fixedPort bool) (int, error) {
+	if !fixedPort || publicHostPort == "" {
+		return 0, nil
+	}
+
+	endpoint, err := types.ParseEndpoint(publicHostPort)
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	return port
+	return endpoint.Port, nil
+}
+
+// reservedOverrideKeys are top-level config sections managed by pb-nebula.
+// Overriding them would desync the config from the stored certificates.
+var reservedOverrideKeys = []string{"pki"}
+
+// ValidateOverrides checks a config override document can be safely merged.
+//
+// VALIDATION CHECKS:
+// - No reserved top-level sections (pki is always generated from the record)
+//
+// PARAMETERS:
+//   - overrides: Parsed config override document (nil is valid)
+//
+// RETURNS:
+// - error: nil if valid, descriptive error naming the offending key
+func (g *Generator) ValidateOverrides(overrides map[string]interface{}) error {
+	for _, key := range reservedOverrideKeys {
+		if _, ok := overrides[key]; ok {
+			return fmt.Errorf("config overrides cannot change the %q section (managed by pb-nebula)", key)
+		}
+	}
+	return nil
+}
+
+// mergeConfig merges overrides into a generated config in place.
+//
+// MERGE SEMANTICS:
+// - Objects are merged recursively (e.g., {"tun": {"mtu": 1400}} keeps tun.dev)
+// - Any other value (scalars, lists) replaces the generated value
+// - null removes the key from the generated config
+//
+// PARAMETERS:
+//   - base: Generated config to modify
+//   - overrides: Override document to merge over base
+func mergeConfig(base, overrides map[string]interface{}) {
+	for key, value := range overrides {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+
+		overrideMap, overrideIsMap := value.(map[string]interface{})
+		baseMap, baseIsMap := base[key].(map[string]interface{})
+		if overrideIsMap && baseIsMap {
+			mergeConfig(baseMap, overrideMap)
+			continue
+		}
+
+		base[key] = value
+	}
 }
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skeeeon/pb-nebula/internal/types"
+)
+
+// TemplateData is the data passed to user-supplied config templates.
+// Field names are part of the template contract - rename with care.
+//
+// EXAMPLE TEMPLATE:
+//
+//	pki:
+//	  ca: |
+//	{{ indent 4 .PKI.CA }}
+//	  cert: |
+//	{{ indent 4 .PKI.Cert }}
+//	  key: |
+//	{{ indent 4 .PKI.Key }}
+//	static_host_map:
+//	{{ toYaml .StaticHostMap | indent 2 }}
+//	lighthouse:
+//	  am_lighthouse: {{ .IsLighthouse }}
+//	  hosts:
+//	{{- range .Lighthouses }}
+//	    - {{ .OverlayIP }}
+//	{{- end }}
+//	listen:
+//	  host: {{ .ListenHost }}
+//	  port: {{ .ListenPort }}
+//	firewall:
+//	  outbound:
+//	{{ toYaml .Firewall.Outbound | indent 4 }}
+//	  inbound:
+//	{{ toYaml .Firewall.Inbound | indent 4 }}
+type TemplateData struct {
+	Hostname       string                 // Host name (as in the certificate)
+	OverlayIP      string                 // Host overlay IP
+	Groups         []string               // Host groups (as in the certificate)
+	IsLighthouse   bool                   // True if this host is a lighthouse
+	IsRelay        bool                   // True if this host is a relay
+	ListenHost     string                 // UDP listen address
+	ListenPort     int                    // UDP listen port
+	AdvertiseAddrs []string               // Canonical lighthouse.advertise_addrs (may be empty)
+	Network        TemplateNetwork        // Network the host belongs to
+	PKI            TemplatePKI            // Certificates, key and blocklist
+	Lighthouses    []types.LighthouseInfo // Lighthouses in the network
+	Relays         []string               // Relay overlay IPs in the network (excluding this host)
+	DNSResolvers   []string               // Overlay DNS resolvers (host:port) served by lighthouses
+	StaticHostMap  map[string][]string    // Lighthouse overlay IP -> public endpoints (nil for lighthouses)
+	Firewall       TemplateFirewall       // Effective firewall rules (defaults applied)
+}
+
+// TemplateNetwork describes the host's network for templates.
+type TemplateNetwork struct {
+	Name string // Network name
+	CIDR string // Network CIDR (e.g., "10.128.0.0/16")
+}
+
+// TemplatePKI holds the PKI material for templates.
+type TemplatePKI struct {
+	CA        string   // PEM encoded CA certificate
+	Cert      string   // PEM encoded host certificate
+	Key       string   // PEM encoded host private key
+	Blocklist []string // Blocklisted certificate fingerprints
+}
+
+// TemplateFirewall holds the effective firewall rules for templates.
+type TemplateFirewall struct {
+	Outbound []map[string]interface{} // Outbound rules in Nebula format
+	Inbound  []map[string]interface{} // Inbound rules in Nebula format
+	Settings map[string]interface{}   // Actions, conntrack and default_local_cidr_any (only keys that are set)
+}
+
+// templateFuncs are the helper functions available to config templates.
+//
+// FUNCTIONS:
+// - indent N TEXT: Indent every line of TEXT by N spaces (for PEM block scalars)
+// - toYaml VALUE: Marshal VALUE to YAML (trailing newline trimmed)
+// - toJson VALUE: Marshal VALUE to JSON (valid YAML flow style)
+var templateFuncs = template.FuncMap{
+	"indent": func(spaces int, text string) string {
+		pad := strings.Repeat(" ", spaces)
+		lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = pad + line
+		}
+		return strings.Join(lines, "\n")
+	},
+	"toYaml": func(value interface{}) (string, error) {
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(out), "\n"), nil
+	},
+	"toJson": func(value interface{}) (string, error) {
+		out, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	},
+}
+
+// RenderTemplate renders a user-supplied config template into a config map.
+// Templates use Go text/template syntax and must produce a YAML document.
+//
+// RENDERING:
+// - Missing keys are errors (typos fail loudly instead of rendering "<no value>")
+// - Output must parse as a YAML mapping
+// - The result is returned as a map so overrides can still be merged over it
+//
+// PARAMETERS:
+//   - content: Template source
+//   - data: Host data exposed to the template
+//
+// RETURNS:
+// - map[string]interface{}: Parsed config
+// - error if the template fails to parse, execute, or produce valid YAML
+func (g *Generator) RenderTemplate(content string, data TemplateData) (map[string]interface{}, error) {
+	tmpl, err := template.New("config").Funcs(templateFuncs).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render config template: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(rendered.Bytes(), &config); err != nil {
+		return nil, fmt.Errorf("config template did not produce valid YAML: %w", err)
+	}
+	if config == nil {
+		return nil, fmt.Errorf("config template produced an empty config")
+	}
+
+	return config, nil
+}
+
+// ValidateTemplate checks a template parses and renders valid YAML with sample data.
+// Used when saving templates so broken templates are rejected before they are assigned.
+//
+// FIREWALL SETTINGS:
+// Settings only contains the keys a network or host sets, so the template is rendered
+// a second time with empty settings. A template reading a settings key directly
+// (.Firewall.Settings.inbound_action) would fail for hosts that leave it unset and is
+// rejected, while `index` or `with` on the map is accepted.
+//
+// PARAMETERS:
+//   - content: Template source
+//
+// RETURNS:
+// - error: nil if the template renders, descriptive error otherwise
+func (g *Generator) ValidateTemplate(content string) error {
+	sample := TemplateData{
+		Hostname:       "template-check",
+		OverlayIP:      "10.0.0.2",
+		Groups:         []string{"example"},
+		IsLighthouse:   false,
+		IsRelay:        false,
+		ListenHost:     "0.0.0.0",
+		ListenPort:     0,
+		AdvertiseAddrs: []string{"203.0.113.2:4242"},
+		Network:        TemplateNetwork{Name: "example", CIDR: "10.0.0.0/24"},
+		PKI: TemplatePKI{
+			CA:        "-----BEGIN NEBULA CERTIFICATE V2-----\n-----END NEBULA CERTIFICATE V2-----\n",
+			Cert:      "-----BEGIN NEBULA CERTIFICATE V2-----\n-----END NEBULA CERTIFICATE V2-----\n",
+			Key:       "-----BEGIN NEBULA X25519 PRIVATE KEY-----\n-----END NEBULA X25519 PRIVATE KEY-----\n",
+			Blocklist: []string{},
+		},
+		Lighthouses: []types.LighthouseInfo{{
+			OverlayIP:       "10.0.0.1",
+			PublicHostPort:  "203.0.113.1:4242",
+			PublicEndpoints: []string{"203.0.113.1:4242", "[2001:db8::1]:4242"},
+		}},
+		Relays:        []string{"10.0.0.1"},
+		DNSResolvers:  []string{"10.0.0.1:53"},
+		StaticHostMap: map[string][]string{"10.0.0.1": {"203.0.113.1:4242", "[2001:db8::1]:4242"}},
+		Firewall: TemplateFirewall{
+			Outbound: []map[string]interface{}{{"port": "any", "proto": "any", "host": "any"}},
+			Inbound:  []map[string]interface{}{{"port": "any", "proto": "icmp", "host": "any"}},
+			Settings: map[string]interface{}{"inbound_action": "reject"},
+		},
+	}
+
+	if _, err := g.RenderTemplate(content, sample); err != nil {
+		return err
+	}
+
+	sample.Firewall.Settings = map[string]interface{}{}
+	if _, err := g.RenderTemplate(content, sample); err != nil {
+		return fmt.Errorf("%w (firewall settings keys may be unset, use index or with to read them)", err)
+	}
+
+	return nil
+}
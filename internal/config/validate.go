@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
+	nebulaconfig "github.com/slackhq/nebula/config"
+)
+
+// ErrInvalidConfig is returned (wrapped) when a generated config would be rejected by Nebula.
+// Re-exported by the pbnebula package so callers can match it with errors.Is.
+var ErrInvalidConfig = errors.New("invalid nebula config")
+
+// ConfigPKI is the pki section of a config as Nebula reads it.
+type ConfigPKI struct {
+	CA   string // PEM encoded CA certificate(s)
+	Cert string // PEM encoded host certificate
+	Key  string // PEM encoded host private key
+}
+
+// ValidateConfig loads a generated config through Nebula's config loader and
+// checks the firewall and lighthouse settings the daemon needs at startup, so a
+// config that would crash the daemon is rejected before it is stored. Templates
+// and config overrides are covered too, since the final YAML is checked.
+//
+// VALIDATION CHECKS (by Nebula's own code):
+// - YAML parses with Nebula's config loader
+//
+// VALIDATION CHECKS (mirroring Nebula's startup - the firewall and lighthouse
+// loaders live in the daemon package, which pulls in the whole daemon):
+// - pki.ca, pki.cert and pki.key are set
+// - firewall.outbound/inbound are lists of valid rules (see firewall.ValidateRules)
+// - Lighthouses listen on a fixed port
+// - lighthouse.hosts are IPs with a static_host_map entry
+// - static_host_map keys are IPs and values are lists of HOST:PORT
+// - lighthouse.advertise_addrs are HOST:PORT
+//
+// The certificate chain is not verified here - see cert.Manager.VerifyHostChain
+// with the returned ConfigPKI.
+//
+// PARAMETERS:
+//   - configYAML: Complete Nebula config (as returned by GenerateHostConfig)
+//
+// RETURNS:
+// - *ConfigPKI: PKI material read from the config
+// - error wrapping ErrInvalidConfig, naming the offending key
+func (g *Generator) ValidateConfig(configYAML string) (*ConfigPKI, error) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	c := nebulaconfig.NewC(logger)
+	if err := c.LoadString(configYAML); err != nil {
+		return nil, fmt.Errorf("%w: failed to load config: %v", ErrInvalidConfig, err)
+	}
+
+	pki := &ConfigPKI{
+		CA:   c.GetString("pki.ca", ""),
+		Cert: c.GetString("pki.cert", ""),
+		Key:  c.GetString("pki.key", ""),
+	}
+	for _, entry := range []struct{ key, value string }{
+		{"pki.ca", pki.CA},
+		{"pki.cert", pki.Cert},
+		{"pki.key", pki.Key},
+	} {
+		if entry.value == "" {
+			return nil, fmt.Errorf("%w: %s is not set", ErrInvalidConfig, entry.key)
+		}
+	}
+
+	for _, table := range []string{"outbound", "inbound"} {
+		if err := validateFirewallTable(c, table); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateLighthouseConfig(c); err != nil {
+		return nil, err
+	}
+
+	return pki, nil
+}
+
+// validateFirewallTable checks one firewall rule table (firewall.outbound or firewall.inbound).
+func validateFirewallTable(c *nebulaconfig.C, table string) error {
+	raw := c.Get("firewall." + table)
+	if raw == nil {
+		return nil
+	}
+
+	list, ok := raw.([]any)
+	if !ok {
+		return fmt.Errorf("%w: firewall.%s must be a list of rules", ErrInvalidConfig, table)
+	}
+
+	rules := make([]map[string]interface{}, len(list))
+	for i, item := range list {
+		rule, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: firewall.%s rule %d must be an object", ErrInvalidConfig, table, i)
+		}
+		rules[i] = rule
+	}
+
+	if err := firewall.ValidateRules(table, rules); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// validateLighthouseConfig checks the lighthouse, listen and static_host_map sections.
+func validateLighthouseConfig(c *nebulaconfig.C) error {
+	if c.GetBool("lighthouse.am_lighthouse", false) && c.GetInt("listen.port", 0) == 0 {
+		return fmt.Errorf("%w: lighthouse.am_lighthouse is enabled but listen.port is not set", ErrInvalidConfig)
+	}
+
+	staticHosts := map[netip.Addr]bool{}
+	for key, value := range c.GetMap("static_host_map", map[string]any{}) {
+		addr, err := netip.ParseAddr(fmt.Sprintf("%v", key))
+		if err != nil {
+			return fmt.Errorf("%w: static_host_map key %q is not an IP address", ErrInvalidConfig, key)
+		}
+
+		endpoints, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%w: static_host_map entry %s must be a list of HOST:PORT", ErrInvalidConfig, addr)
+		}
+		for _, endpoint := range endpoints {
+			if err := validateHostPort(fmt.Sprintf("%v", endpoint)); err != nil {
+				return fmt.Errorf("%w: static_host_map entry %s: %v", ErrInvalidConfig, addr, err)
+			}
+		}
+		staticHosts[addr] = true
+	}
+
+	for _, host := range c.GetStringSlice("lighthouse.hosts", []string{}) {
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return fmt.Errorf("%w: lighthouse.hosts entry %q is not an IP address", ErrInvalidConfig, host)
+		}
+		if !staticHosts[addr] {
+			return fmt.Errorf("%w: lighthouse %s does not have a static_host_map entry", ErrInvalidConfig, addr)
+		}
+	}
+
+	for _, addr := range c.GetStringSlice("lighthouse.advertise_addrs", []string{}) {
+		if err := validateHostPort(addr); err != nil {
+			return fmt.Errorf("%w: lighthouse.advertise_addrs: %v", ErrInvalidConfig, err)
+		}
+	}
+
+	return nil
+}
+
+// validateHostPort checks an endpoint is HOST:PORT with a numeric port.
+func validateHostPort(endpoint string) error {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("%q must be HOST:PORT: %v", endpoint, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%q has no host", endpoint)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("%q has an invalid port", endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package firewall
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/skeeeon/pb-nebula/internal/types"
+)
+
+// DefaultRules returns the rules injected for firewall directions left without rules.
+//
+// MODES:
+// - recommended (or empty): outbound allow all, inbound ICMP from any (Nebula recommendations)
+// - none: no rules - Nebula denies everything in an empty direction
+// - custom: the configured Outbound/Inbound lists
+//
+// RETURNS:
+// - outbound, inbound: Fresh copies of the default rules (safe to modify)
+// - error wrapping ErrInvalidFirewall for an unknown mode
+func DefaultRules(defaults types.FirewallDefaults) (outbound, inbound []map[string]interface{}, err error) {
+	switch defaults.Mode {
+	case "", types.FirewallDefaultsRecommended:
+		outbound = []map[string]interface{}{
+			{"port": "any", "proto": "any", "host": "any"},
+		}
+		// Nebula recommended default: Allow ICMP for troubleshooting
+		inbound = []map[string]interface{}{
+			{"port": "any", "proto": "icmp", "host": "any"},
+		}
+		return outbound, inbound, nil
+	case types.FirewallDefaultsNone:
+		// Empty (not nil) so the generated YAML reads "[]" rather than "null"
+		return []map[string]interface{}{}, []map[string]interface{}{}, nil
+	case types.FirewallDefaultsCustom:
+		return cloneRules(defaults.Outbound), cloneRules(defaults.Inbound), nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown default firewall mode %q", ErrInvalidFirewall, defaults.Mode)
+	}
+}
+
+// ValidateDefaults checks default firewall settings (Options or a network).
+//
+// VALIDATION CHECKS:
+// - Mode is recommended, none or custom (empty means recommended)
+// - Custom rules are only set in custom mode and are valid (see ValidateRules)
+//
+// RETURNS:
+// - error wrapping ErrInvalidFirewall, nil if the settings are valid
+func ValidateDefaults(defaults types.FirewallDefaults) error {
+	switch defaults.Mode {
+	case "", types.FirewallDefaultsRecommended, types.FirewallDefaultsNone:
+		if len(defaults.Outbound) > 0 || len(defaults.Inbound) > 0 {
+			return fmt.Errorf("%w: default firewall rules require mode %q", ErrInvalidFirewall, types.FirewallDefaultsCustom)
+		}
+		return nil
+	case types.FirewallDefaultsCustom:
+		if err := ValidateRules("default outbound", defaults.Outbound); err != nil {
+			return err
+		}
+		return ValidateRules("default inbound", defaults.Inbound)
+	default:
+		return fmt.Errorf("%w: default firewall mode must be %s, %s or %s, got %q", ErrInvalidFirewall,
+			types.FirewallDefaultsRecommended, types.FirewallDefaultsNone, types.FirewallDefaultsCustom, defaults.Mode)
+	}
+}
+
+// cloneRules copies a rule list so callers can't modify shared defaults.
+func cloneRules(rules []map[string]interface{}) []map[string]interface{} {
+	cloned := make([]map[string]interface{}, len(rules))
+	for i, rule := range rules {
+		cloned[i] = maps.Clone(rule)
+	}
+	return cloned
+}
@@ -0,0 +1,204 @@
+package firewall
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Lint finding codes.
+const (
+	FindingUnknownGroup = "unknown_group"  // Rule references a group no host in the network carries
+	FindingDuplicate    = "duplicate_rule" // Rule repeats an earlier rule
+	FindingShadowed     = "shadowed_rule"  // Rule is fully covered by a broader "host: any" rule
+	FindingBroadInbound = "broad_inbound"  // Inbound rule allows any port and protocol from anyone
+)
+
+// Finding severities.
+const (
+	SeverityWarning = "warning" // Likely a mistake or a security risk
+	SeverityInfo    = "info"    // Harmless but redundant
+)
+
+// Finding is a problem detected in a host's effective firewall.
+type Finding struct {
+	Code      string                 `json:"code"`       // One of the Finding* codes
+	Severity  string                 `json:"severity"`   // "warning" or "info"
+	Direction string                 `json:"direction"`  // "outbound" or "inbound"
+	RuleIndex int                    `json:"rule_index"` // Index of the offending rule in its direction
+	Rule      map[string]interface{} `json:"rule"`       // Offending rule
+	Message   string                 `json:"message"`    // Human-readable explanation
+}
+
+// Lint inspects a host's effective firewall (as returned by the config generator,
+// defaults included) and reports likely mistakes. Findings never block saving.
+//
+// CHECKS:
+// - unknown_group (warning): group/groups naming a group no host in the network carries
+// - duplicate_rule (info): rule identical to an earlier rule in the same direction
+// - shadowed_rule (info): rule fully covered by a broader rule matching any peer
+// - broad_inbound (warning): inbound rule allowing any port and protocol from any peer
+//
+// PARAMETERS:
+//   - outbound, inbound: Effective rules of the host
+//   - networkGroups: Every group carried by a host in the network
+//
+// RETURNS:
+// - []Finding ordered by direction (outbound first) and rule index, empty if clean
+func Lint(outbound, inbound []map[string]interface{}, networkGroups []string) []Finding {
+	findings := []Finding{}
+	findings = append(findings, lintDirection("outbound", outbound, networkGroups)...)
+	findings = append(findings, lintDirection("inbound", inbound, networkGroups)...)
+	return findings
+}
+
+// lintDirection runs every check over the rules of one direction.
+func lintDirection(direction string, rules []map[string]interface{}, networkGroups []string) []Finding {
+	var findings []Finding
+	add := func(code, severity string, index int, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Code:      code,
+			Severity:  severity,
+			Direction: direction,
+			RuleIndex: index,
+			Rule:      rules[index],
+			Message:   fmt.Sprintf("%s rule %d %s: ", direction, index, formatRule(rules[index])) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	for i, rule := range rules {
+		for _, group := range ruleGroups(rule) {
+			if group != "any" && !slices.Contains(networkGroups, group) {
+				add(FindingUnknownGroup, SeverityWarning, i, "no host in the network carries group %q", group)
+			}
+		}
+
+		if direction == "inbound" && isAnyPeer(rule) && !hasConstraints(rule) && coversEverything(rule) {
+			add(FindingBroadInbound, SeverityWarning, i, "allows any port and protocol from any host")
+		}
+
+		if j := slices.IndexFunc(rules[:i], func(earlier map[string]interface{}) bool {
+			return reflect.DeepEqual(normalizeRule(earlier), normalizeRule(rule))
+		}); j >= 0 {
+			add(FindingDuplicate, SeverityInfo, i, "duplicates rule %d", j)
+			continue
+		}
+
+		for j, broader := range rules {
+			// Equally broad rules cover each other - only the later one is reported
+			if j != i && shadows(broader, rule) && (j < i || !shadows(rule, broader)) {
+				add(FindingShadowed, SeverityInfo, i, "is already allowed by rule %d %s", j, formatRule(broader))
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// ruleGroups returns the groups referenced by a rule (group and groups).
+func ruleGroups(rule map[string]interface{}) []string {
+	var groups []string
+	for _, key := range []string{KeyGroup, KeyGroups} {
+		if value, ok := rule[key]; ok {
+			parsed, err := ParseGroups(value)
+			if err == nil {
+				groups = append(groups, parsed...)
+			}
+		}
+	}
+	return groups
+}
+
+// isAnyPeer reports whether a rule selects every remote peer.
+func isAnyPeer(rule map[string]interface{}) bool {
+	if host, _ := rule[KeyHost].(string); host == "any" {
+		return true
+	}
+	if slices.Contains(ruleGroups(rule), "any") {
+		return true
+	}
+	cidr, _ := rule[KeyCIDR].(string)
+	return cidr == "0.0.0.0/0" || cidr == "::/0"
+}
+
+// hasConstraints reports whether a rule is narrowed by CA or local CIDR constraints.
+func hasConstraints(rule map[string]interface{}) bool {
+	for _, key := range []string{KeyCAName, KeyCASha, KeyLocalCIDR} {
+		if _, ok := rule[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// coversEverything reports whether a rule allows every protocol and port.
+func coversEverything(rule map[string]interface{}) bool {
+	proto, _ := rule[KeyProto].(string)
+	start, end, err := ParsePort(rule[KeyPort])
+	return strings.EqualFold(proto, "any") && err == nil && start == 0 && end == 65535
+}
+
+// shadows reports whether a broader rule matching any peer makes a rule redundant.
+// The broader rule must cover the protocol and every port of the rule, and carry
+// no CA/local CIDR constraint the rule doesn't share.
+func shadows(broader, rule map[string]interface{}) bool {
+	if !isAnyPeer(broader) {
+		return false
+	}
+
+	for _, key := range []string{KeyCAName, KeyCASha, KeyLocalCIDR} {
+		if value, ok := broader[key]; ok && value != rule[key] {
+			return false
+		}
+	}
+
+	broaderProto, _ := broader[KeyProto].(string)
+	ruleProto, _ := rule[KeyProto].(string)
+	if !strings.EqualFold(broaderProto, "any") && !strings.EqualFold(broaderProto, ruleProto) {
+		return false
+	}
+
+	broaderStart, broaderEnd, err := ParsePort(broader[KeyPort])
+	if err != nil {
+		return false
+	}
+	ruleStart, ruleEnd, err := ParsePort(rule[KeyPort])
+	if err != nil {
+		return false
+	}
+	return broaderStart <= ruleStart && ruleEnd <= broaderEnd
+}
+
+// normalizeRule returns a comparable form of a rule, so "443" and 443, "TCP" and
+// "tcp", or group "web" and groups ["web"] are treated as the same rule.
+func normalizeRule(rule map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(rule))
+	for key, value := range rule {
+		switch key {
+		case KeyPort:
+			start, end, err := ParsePort(value)
+			if err != nil {
+				normalized[key] = value
+				continue
+			}
+			normalized[key] = [2]int{start, end}
+		case KeyProto:
+			proto, _ := value.(string)
+			normalized[key] = strings.ToLower(proto)
+		case KeyGroup, KeyGroups:
+			groups, err := ParseGroups(value)
+			if err != nil {
+				normalized[key] = value
+				continue
+			}
+			groups = slices.Clone(groups)
+			slices.Sort(groups)
+			normalized[KeyGroups] = strings.Join(slices.Compact(groups), ",")
+		default:
+			normalized[key] = value
+		}
+	}
+	return normalized
+}
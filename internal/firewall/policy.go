@@ -0,0 +1,93 @@
+package firewall
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/skeeeon/pb-nebula/internal/types"
+)
+
+// CompilePolicies returns the network policy rules that apply to a host.
+//
+// POLICY MATCHING:
+// - Inactive policies are skipped
+// - Policies without target groups apply to every host in the network
+// - Otherwise the host must carry at least one of the target groups
+//
+// Policies are compiled in the given order; callers place the result in front of
+// the host's own rules (Nebula allows a packet if any rule matches, so order
+// doesn't change the outcome, only readability of the generated config).
+//
+// PARAMETERS:
+//   - policies: Firewall policies of the host's network
+//   - hostGroups: Groups embedded in the host certificate
+//
+// RETURNS:
+// - outbound, inbound: Compiled policy rules for the host
+// - error wrapping ErrInvalidFirewall if a policy is malformed
+func CompilePolicies(policies []types.FirewallPolicyRecord, hostGroups []string) (outbound, inbound []map[string]interface{}, err error) {
+	for _, policy := range policies {
+		if !policy.Active {
+			continue
+		}
+
+		targetGroups, err := policy.GetTargetGroups()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: policy %s: target_groups must be a JSON array of strings: %v", ErrInvalidFirewall, policy.Name, err)
+		}
+		if len(targetGroups) > 0 && !slices.ContainsFunc(targetGroups, func(group string) bool {
+			return slices.Contains(hostGroups, group)
+		}) {
+			continue
+		}
+
+		rules, err := policy.GetRules()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: policy %s: rules must be a JSON array of objects: %v", ErrInvalidFirewall, policy.Name, err)
+		}
+
+		switch policy.Direction {
+		case types.FirewallDirectionInbound:
+			inbound = append(inbound, rules...)
+		case types.FirewallDirectionOutbound:
+			outbound = append(outbound, rules...)
+		default:
+			return nil, nil, fmt.Errorf("%w: policy %s: direction must be inbound or outbound, got %q", ErrInvalidFirewall, policy.Name, policy.Direction)
+		}
+	}
+
+	return outbound, inbound, nil
+}
+
+// ValidatePolicy checks a firewall policy before it is saved.
+//
+// VALIDATION CHECKS:
+// - direction is inbound or outbound
+// - target_groups is a JSON array of non-empty strings
+// - rules is a non-empty JSON array of valid rules (see ValidateRules)
+//
+// RETURNS:
+// - error wrapping ErrInvalidFirewall, nil if the policy is valid
+func ValidatePolicy(policy types.FirewallPolicyRecord) error {
+	if policy.Direction != types.FirewallDirectionInbound && policy.Direction != types.FirewallDirectionOutbound {
+		return fmt.Errorf("%w: direction must be inbound or outbound, got %q", ErrInvalidFirewall, policy.Direction)
+	}
+
+	targetGroups, err := policy.GetTargetGroups()
+	if err != nil {
+		return fmt.Errorf("%w: target_groups must be a JSON array of strings: %v", ErrInvalidFirewall, err)
+	}
+	if slices.Contains(targetGroups, "") {
+		return fmt.Errorf("%w: target_groups cannot contain empty group names", ErrInvalidFirewall)
+	}
+
+	rules, err := policy.GetRules()
+	if err != nil {
+		return fmt.Errorf("%w: rules must be a JSON array of objects: %v", ErrInvalidFirewall, err)
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("%w: policy must contain at least one rule", ErrInvalidFirewall)
+	}
+
+	return ValidateRules(policy.Direction, rules)
+}
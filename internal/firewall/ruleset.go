@@ -0,0 +1,53 @@
+package firewall
+
+import (
+	"fmt"
+
+	"github.com/skeeeon/pb-nebula/internal/types"
+)
+
+// CompileRuleSets returns the combined rules of the rule sets referenced by a host.
+// Rule sets are compiled in the given order; callers place the result in front of
+// the host's inline rules.
+//
+// PARAMETERS:
+//   - ruleSets: Rule sets referenced by the host
+//
+// RETURNS:
+// - outbound, inbound: Combined rule set rules for the host
+// - error wrapping ErrInvalidFirewall if a rule set is malformed
+func CompileRuleSets(ruleSets []types.FirewallRuleSetRecord) (outbound, inbound []map[string]interface{}, err error) {
+	for _, ruleSet := range ruleSets {
+		setOutbound, setInbound, err := ruleSet.GetRules()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: rule set %s: rules must be JSON arrays of objects: %v", ErrInvalidFirewall, ruleSet.Name, err)
+		}
+		outbound = append(outbound, setOutbound...)
+		inbound = append(inbound, setInbound...)
+	}
+
+	return outbound, inbound, nil
+}
+
+// ValidateRuleSet checks a firewall rule set before it is saved.
+//
+// VALIDATION CHECKS:
+// - outbound and inbound are JSON arrays of valid rules (see ValidateRules)
+// - At least one rule in either direction
+//
+// RETURNS:
+// - error wrapping ErrInvalidFirewall, nil if the rule set is valid
+func ValidateRuleSet(ruleSet types.FirewallRuleSetRecord) error {
+	outbound, inbound, err := ruleSet.GetRules()
+	if err != nil {
+		return fmt.Errorf("%w: rules must be JSON arrays of objects: %v", ErrInvalidFirewall, err)
+	}
+	if len(outbound) == 0 && len(inbound) == 0 {
+		return fmt.Errorf("%w: rule set must contain at least one rule", ErrInvalidFirewall)
+	}
+
+	if err := ValidateRules(types.FirewallDirectionOutbound, outbound); err != nil {
+		return err
+	}
+	return ValidateRules(types.FirewallDirectionInbound, inbound)
+}
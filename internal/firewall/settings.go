@@ -0,0 +1,110 @@
+package firewall
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/skeeeon/pb-nebula/internal/types"
+)
+
+// ValidateSettings checks firewall settings (network or host).
+//
+// VALIDATION CHECKS:
+// - outbound_action/inbound_action: "drop" or "reject"
+// - conntrack timeouts: Positive Go durations (e.g., "90s", "12m", "2h")
+//
+// RETURNS:
+// - error wrapping ErrInvalidFirewall, nil if the settings are valid
+func ValidateSettings(settings types.FirewallSettings) error {
+	actions := []struct{ name, value string }{
+		{"outbound_action", settings.OutboundAction},
+		{"inbound_action", settings.InboundAction},
+	}
+	for _, action := range actions {
+		switch action.value {
+		case "", types.FirewallActionDrop, types.FirewallActionReject:
+		default:
+			return fmt.Errorf("%w: %s must be %q or %q, got %q", ErrInvalidFirewall,
+				action.name, types.FirewallActionDrop, types.FirewallActionReject, action.value)
+		}
+	}
+
+	timeouts := []struct{ name, value string }{
+		{"tcp_timeout", settings.Conntrack.TCPTimeout},
+		{"udp_timeout", settings.Conntrack.UDPTimeout},
+		{"default_timeout", settings.Conntrack.DefaultTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(timeout.value)
+		if err != nil {
+			return fmt.Errorf("%w: conntrack %s must be a duration like \"12m\": %v", ErrInvalidFirewall, timeout.name, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("%w: conntrack %s must be positive, got %q", ErrInvalidFirewall, timeout.name, timeout.value)
+		}
+	}
+
+	return nil
+}
+
+// MergeSettings returns base with every field set in override replacing it.
+// Used to layer host settings over network settings.
+func MergeSettings(base, override types.FirewallSettings) types.FirewallSettings {
+	merged := base
+	if override.OutboundAction != "" {
+		merged.OutboundAction = override.OutboundAction
+	}
+	if override.InboundAction != "" {
+		merged.InboundAction = override.InboundAction
+	}
+	if override.DefaultLocalCIDRAny != nil {
+		merged.DefaultLocalCIDRAny = override.DefaultLocalCIDRAny
+	}
+	if override.Conntrack.TCPTimeout != "" {
+		merged.Conntrack.TCPTimeout = override.Conntrack.TCPTimeout
+	}
+	if override.Conntrack.UDPTimeout != "" {
+		merged.Conntrack.UDPTimeout = override.Conntrack.UDPTimeout
+	}
+	if override.Conntrack.DefaultTimeout != "" {
+		merged.Conntrack.DefaultTimeout = override.Conntrack.DefaultTimeout
+	}
+	return merged
+}
+
+// RenderSettings returns the settings as keys of Nebula's firewall section.
+// Unset fields are left out so Nebula applies its own defaults.
+//
+// RETURNS:
+// - map with outbound_action, inbound_action, default_local_cidr_any and conntrack (only the keys that are set)
+func RenderSettings(settings types.FirewallSettings) map[string]interface{} {
+	section := map[string]interface{}{}
+	if settings.OutboundAction != "" {
+		section["outbound_action"] = settings.OutboundAction
+	}
+	if settings.InboundAction != "" {
+		section["inbound_action"] = settings.InboundAction
+	}
+	if settings.DefaultLocalCIDRAny != nil {
+		section["default_local_cidr_any"] = *settings.DefaultLocalCIDRAny
+	}
+
+	conntrack := map[string]interface{}{}
+	if settings.Conntrack.TCPTimeout != "" {
+		conntrack["tcp_timeout"] = settings.Conntrack.TCPTimeout
+	}
+	if settings.Conntrack.UDPTimeout != "" {
+		conntrack["udp_timeout"] = settings.Conntrack.UDPTimeout
+	}
+	if settings.Conntrack.DefaultTimeout != "" {
+		conntrack["default_timeout"] = settings.Conntrack.DefaultTimeout
+	}
+	if len(conntrack) > 0 {
+		section["conntrack"] = conntrack
+	}
+
+	return section
+}
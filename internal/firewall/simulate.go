@@ -0,0 +1,243 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Peer is a host as seen by Nebula's firewall: its certificate identity and overlay IP.
+type Peer struct {
+	Name   string   `json:"name"`    // Certificate name (hostname)
+	IP     string   `json:"ip"`      // Overlay IP
+	Groups []string `json:"groups"`  // Certificate groups
+	CAName string   `json:"ca_name"` // Name of the CA that signed the certificate
+	CASha  string   `json:"ca_sha"`  // Fingerprint of the CA that signed the certificate
+}
+
+// RuleMatch is the outcome of evaluating one host's rules for one direction.
+type RuleMatch struct {
+	Host      string                 `json:"host"`           // Host whose firewall was evaluated
+	Direction string                 `json:"direction"`      // "outbound" or "inbound"
+	Allowed   bool                   `json:"allowed"`        // True if a rule matched
+	RuleIndex int                    `json:"rule_index"`     // Index of the matching rule (-1 if none)
+	Rule      map[string]interface{} `json:"rule,omitempty"` // Matching rule
+	Reason    string                 `json:"reason"`         // Human-readable explanation
+}
+
+// Verdict answers "can host A reach host B on PROTO/PORT?".
+type Verdict struct {
+	From        Peer      `json:"from"`        // Initiating host (A)
+	To          Peer      `json:"to"`          // Destination host (B)
+	Proto       string    `json:"proto"`       // tcp, udp or icmp
+	Port        int       `json:"port"`        // Destination port (0 for icmp)
+	Allowed     bool      `json:"allowed"`     // True if both directions allow the traffic
+	Outbound    RuleMatch `json:"outbound"`    // A's outbound rules evaluated against B
+	Inbound     RuleMatch `json:"inbound"`     // B's inbound rules evaluated against A
+	Explanation string    `json:"explanation"` // Summary of the verdict
+}
+
+// Simulate evaluates whether traffic initiated by one host reaches another.
+// Replies are not evaluated separately: Nebula's connection tracking lets them through.
+//
+// EVALUATION:
+// 1. A's outbound rules are matched with B as the remote peer
+// 2. B's inbound rules are matched with A as the remote peer
+// 3. Traffic is allowed only if both directions have a matching rule (Nebula is deny-by-default)
+//
+// PARAMETERS:
+//   - from, to: Initiating (A) and destination (B) hosts
+//   - fromOutbound: A's outbound rules as rendered in its generated config
+//   - toInbound: B's inbound rules as rendered in its generated config
+//   - proto: tcp, udp or icmp
+//   - port: Destination port (1-65535, ignored for icmp)
+//
+// RETURNS:
+// - Verdict with the matching rule (or reason for denial) in each direction
+// - error if the query or a rule is malformed
+func Simulate(from, to Peer, fromOutbound, toInbound []map[string]interface{}, proto string, port int) (*Verdict, error) {
+	proto = strings.ToLower(proto)
+	switch proto {
+	case "tcp", "udp":
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port must be 1-65535, got %d", port)
+		}
+	case "icmp":
+		port = 0
+	default:
+		return nil, fmt.Errorf("proto must be tcp, udp or icmp, got %q", proto)
+	}
+
+	outbound, err := EvaluateRules("outbound", fromOutbound, from, to, proto, port)
+	if err != nil {
+		return nil, err
+	}
+	inbound, err := EvaluateRules("inbound", toInbound, to, from, proto, port)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict := &Verdict{
+		From:     from,
+		To:       to,
+		Proto:    proto,
+		Port:     port,
+		Allowed:  outbound.Allowed && inbound.Allowed,
+		Outbound: outbound,
+		Inbound:  inbound,
+	}
+
+	target := proto
+	if proto != "icmp" {
+		target = fmt.Sprintf("%s/%d", proto, port)
+	}
+	switch {
+	case verdict.Allowed:
+		verdict.Explanation = fmt.Sprintf("%s can reach %s on %s: %s; %s", from.Name, to.Name, target, outbound.Reason, inbound.Reason)
+	case !outbound.Allowed:
+		verdict.Explanation = fmt.Sprintf("%s cannot reach %s on %s: %s", from.Name, to.Name, target, outbound.Reason)
+	default:
+		verdict.Explanation = fmt.Sprintf("%s cannot reach %s on %s: %s", from.Name, to.Name, target, inbound.Reason)
+	}
+
+	return verdict, nil
+}
+
+// EvaluateRules finds the first rule of a host that allows traffic with a remote peer.
+// Nebula allows a packet if any rule matches, so the first match is reported.
+//
+// PARAMETERS:
+//   - direction: "outbound" or "inbound"
+//   - rules: Rules of the local host for that direction
+//   - local: Host owning the rules
+//   - remote: Peer on the other end of the connection
+//   - proto: tcp, udp or icmp
+//   - port: Destination port (0 for icmp)
+//
+// RETURNS:
+// - RuleMatch describing the matching rule, or why nothing matched
+// - error if a rule is malformed
+func EvaluateRules(direction string, rules []map[string]interface{}, local, remote Peer, proto string, port int) (RuleMatch, error) {
+	for i, rule := range rules {
+		matched, err := matchRule(rule, local, remote, proto, port)
+		if err != nil {
+			return RuleMatch{}, fmt.Errorf("%w: %s %s rule %d: %v", ErrInvalidFirewall, local.Name, direction, i, err)
+		}
+		if matched {
+			return RuleMatch{
+				Host:      local.Name,
+				Direction: direction,
+				Allowed:   true,
+				RuleIndex: i,
+				Rule:      rule,
+				Reason:    fmt.Sprintf("%s rule %d of %s %s matched", direction, i, local.Name, formatRule(rule)),
+			}, nil
+		}
+	}
+
+	return RuleMatch{
+		Host:      local.Name,
+		Direction: direction,
+		RuleIndex: -1,
+		Reason:    fmt.Sprintf("no %s rule of %s matched %s (%d rules, default deny)", direction, local.Name, remote.Name, len(rules)),
+	}, nil
+}
+
+// matchRule reports whether a rule allows traffic with a remote peer.
+//
+// NEBULA MATCHING SEMANTICS:
+// - proto and port must match (icmp ignores the port, "fragment" never matches a port)
+// - ca_name, ca_sha and local_cidr must all match when set
+// - host, group, groups and cidr select the remote peer: ANY of them matching is enough
+// - groups requires the peer to carry every listed group
+// - host "any", group "any" and a /0 cidr match every peer, as does a rule without remote selectors
+func matchRule(rule map[string]interface{}, local, remote Peer, proto string, port int) (bool, error) {
+	ruleProto, _ := rule[KeyProto].(string)
+	ruleProto = strings.ToLower(ruleProto)
+	if ruleProto != "any" && ruleProto != proto {
+		return false, nil
+	}
+
+	if proto != "icmp" {
+		start, end, err := ParsePort(rule[KeyPort])
+		if err != nil {
+			return false, err
+		}
+		if port < start || port > end {
+			return false, nil
+		}
+	}
+
+	if caName, ok := rule[KeyCAName].(string); ok && caName != remote.CAName {
+		return false, nil
+	}
+	if caSha, ok := rule[KeyCASha].(string); ok && caSha != remote.CASha {
+		return false, nil
+	}
+	if localCIDR, ok := rule[KeyLocalCIDR].(string); ok {
+		contains, err := cidrContains(localCIDR, local.IP)
+		if err != nil || !contains {
+			return false, err
+		}
+	}
+
+	hasSelector := false
+
+	if host, ok := rule[KeyHost].(string); ok {
+		hasSelector = true
+		if host == "any" || host == remote.Name {
+			return true, nil
+		}
+	}
+
+	for _, key := range []string{KeyGroup, KeyGroups} {
+		value, ok := rule[key]
+		if !ok {
+			continue
+		}
+		hasSelector = true
+		groups, err := ParseGroups(value)
+		if err != nil {
+			return false, err
+		}
+		if slices.Contains(groups, "any") || !slices.ContainsFunc(groups, func(group string) bool {
+			return !slices.Contains(remote.Groups, group)
+		}) {
+			return true, nil
+		}
+	}
+
+	if cidr, ok := rule[KeyCIDR].(string); ok {
+		hasSelector = true
+		contains, err := cidrContains(cidr, remote.IP)
+		if err != nil {
+			return false, err
+		}
+		if contains {
+			return true, nil
+		}
+	}
+
+	return !hasSelector, nil
+}
+
+// cidrContains reports whether a CIDR contains an IP address.
+func cidrContains(cidr, ip string) (bool, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, fmt.Errorf("%q is not a valid CIDR", cidr)
+	}
+	parsed := net.ParseIP(ip)
+	return parsed != nil && network.Contains(parsed), nil
+}
+
+// formatRule renders a rule as compact JSON (keys sorted) for explanations.
+func formatRule(rule map[string]interface{}) string {
+	encoded, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Sprint(rule)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,240 @@
+// Package firewall provides Nebula firewall rule validation and policy compilation
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidFirewall is returned (wrapped) for every firewall rule validation failure.
+// Re-exported by the pbnebula package so callers can match it with errors.Is.
+var ErrInvalidFirewall = errors.New("invalid firewall rules")
+
+// Rule keys understood by Nebula's firewall.
+const (
+	KeyPort      = "port"
+	KeyProto     = "proto"
+	KeyHost      = "host"
+	KeyGroup     = "group"
+	KeyGroups    = "groups"
+	KeyCIDR      = "cidr"
+	KeyLocalCIDR = "local_cidr"
+	KeyCAName    = "ca_name"
+	KeyCASha     = "ca_sha"
+)
+
+// knownKeys are the rule keys accepted by ValidateRules.
+var knownKeys = map[string]bool{
+	KeyPort: true, KeyProto: true, KeyHost: true, KeyGroup: true, KeyGroups: true,
+	KeyCIDR: true, KeyLocalCIDR: true, KeyCAName: true, KeyCASha: true,
+}
+
+// selectorKeys are the keys that select which peers a rule applies to.
+// Nebula refuses rules without at least one of them.
+var selectorKeys = []string{KeyHost, KeyGroup, KeyGroups, KeyCIDR, KeyLocalCIDR, KeyCAName, KeyCASha}
+
+// validProtos are the protocols understood by Nebula.
+var validProtos = map[string]bool{"any": true, "tcp": true, "udp": true, "icmp": true}
+
+// ValidateRules checks a list of firewall rules in Nebula's native format.
+// Broken rules are rejected at save time rather than crashing Nebula on the host.
+//
+// VALIDATION CHECKS:
+// - Known keys only: port, proto, host, group, groups, cidr, local_cidr, ca_name, ca_sha
+// - port: "any", "fragment", a port (0-65535) or a range ("8000-8100")
+// - proto: any, tcp, udp or icmp
+// - cidr, local_cidr: valid CIDR notation
+// - group is a string, groups a string or list of strings (not both)
+// - At least one selector (host, group, groups, cidr, local_cidr, ca_name, ca_sha)
+//
+// PARAMETERS:
+//   - direction: "inbound" or "outbound" (used in error messages)
+//   - rules: Rules as parsed from the host record
+//
+// RETURNS:
+// - error wrapping ErrInvalidFirewall naming the offending rule, nil if all rules are valid
+func ValidateRules(direction string, rules []map[string]interface{}) error {
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("%w: %s rule %d: %v", ErrInvalidFirewall, direction, i, err)
+		}
+	}
+	return nil
+}
+
+// validateRule checks a single firewall rule.
+func validateRule(rule map[string]interface{}) error {
+	// Report unknown keys in a stable order
+	keys := make([]string, 0, len(rule))
+	for key := range rule {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !knownKeys[key] {
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	port, ok := rule[KeyPort]
+	if !ok {
+		return fmt.Errorf("port is required")
+	}
+	if _, _, err := ParsePort(port); err != nil {
+		return err
+	}
+
+	proto, ok := rule[KeyProto]
+	if !ok {
+		return fmt.Errorf("proto is required")
+	}
+	protoStr, ok := proto.(string)
+	if !ok || !validProtos[strings.ToLower(protoStr)] {
+		return fmt.Errorf("proto must be one of any, tcp, udp, icmp, got %v", proto)
+	}
+
+	for _, key := range []string{KeyHost, KeyGroup, KeyCAName, KeyCASha} {
+		if value, ok := rule[key]; ok {
+			if s, isString := value.(string); !isString || s == "" {
+				return fmt.Errorf("%s must be a non-empty string", key)
+			}
+		}
+	}
+
+	for _, key := range []string{KeyCIDR, KeyLocalCIDR} {
+		if value, ok := rule[key]; ok {
+			s, isString := value.(string)
+			if !isString {
+				return fmt.Errorf("%s must be a string", key)
+			}
+			if _, _, err := net.ParseCIDR(s); err != nil {
+				return fmt.Errorf("%s %q is not a valid CIDR", key, s)
+			}
+		}
+	}
+
+	if value, ok := rule[KeyGroups]; ok {
+		if _, err := ParseGroups(value); err != nil {
+			return err
+		}
+		if _, hasGroup := rule[KeyGroup]; hasGroup {
+			return fmt.Errorf("only one of group or groups may be set")
+		}
+	}
+
+	for _, key := range selectorKeys {
+		if _, ok := rule[key]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("at least one of %s is required", strings.Join(selectorKeys, ", "))
+}
+
+// ParsePort parses a rule port value into an inclusive range.
+//
+// PORT FORMATS:
+// - "any" or 0: every port (returns 0, 65535)
+// - "fragment": packet fragments (returns -1, -1)
+// - "443" or 443: a single port
+// - "8000-8100": an inclusive range
+//
+// RETURNS:
+// - start, end: Inclusive port range
+// - error if the value isn't a valid port specification
+func ParsePort(value interface{}) (int, int, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, 0, fmt.Errorf("port must be an integer, got %v", v)
+		}
+		s = strconv.Itoa(int(v))
+	case int:
+		s = strconv.Itoa(v)
+	default:
+		return 0, 0, fmt.Errorf("port must be a string or number, got %v", value)
+	}
+
+	switch strings.ToLower(s) {
+	case "any":
+		return 0, 65535, nil
+	case "fragment":
+		return -1, -1, nil
+	}
+
+	if startStr, endStr, isRange := strings.Cut(s, "-"); isRange {
+		start, err := parsePortNumber(startStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		end, err := parsePortNumber(endStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		if start > end {
+			return 0, 0, fmt.Errorf("port range %q has start after end", s)
+		}
+		return start, end, nil
+	}
+
+	port, err := parsePortNumber(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if port == 0 {
+		return 0, 65535, nil // Nebula treats port 0 as any
+	}
+	return port, port, nil
+}
+
+// parsePortNumber parses a single port number (0-65535).
+func parsePortNumber(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("port %q must be any, fragment, 0-65535 or a range", s)
+	}
+	return port, nil
+}
+
+// ParseGroups parses a rule groups value (a string or list of strings).
+//
+// RETURNS:
+// - []string: Group names
+// - error if the value isn't a non-empty string or list of non-empty strings
+func ParseGroups(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil, fmt.Errorf("groups cannot be empty")
+		}
+		return []string{v}, nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("groups cannot be empty")
+		}
+		groups := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("groups must contain non-empty strings")
+			}
+			groups[i] = s
+		}
+		return groups, nil
+	case []string:
+		if len(v) == 0 || slices.Contains(v, "") {
+			return nil, fmt.Errorf("groups must contain non-empty strings")
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("groups must be a string or list of strings")
+	}
+}
@@ -0,0 +1,449 @@
+// Package routes provides the pb-nebula REST API endpoints
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	gosync "sync"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/skeeeon/pb-nebula/internal/cert"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+	"github.com/skeeeon/pb-nebula/internal/types"
+	"github.com/skeeeon/pb-nebula/internal/utils"
+)
+
+// Manager registers custom REST routes that sit next to PocketBase's record API.
+// Record CRUD stays with PocketBase - these routes only expose derived data and
+// operations that don't map onto a single record update.
+//
+// ROUTE PREFIX:
+// All routes live under /api/nebula/ to avoid clashing with PocketBase routes.
+//
+// AUTHENTICATION:
+// - Host routes: Require authentication as a record of a tenant's host collection
+// - Admin routes: Require superuser authentication
+//
+// MULTI-TENANT:
+// Each Setup call is a tenant, named by its host collection. The routes are
+// registered once per app: host routes serve the tenant of the authenticated
+// host, admin routes select the tenant with ?tenant=.
+type Manager struct {
+	app     *pocketbase.PocketBase // PocketBase application instance
+	tenants []tenant               // Tenants the routes can operate on
+}
+
+// tenant holds the services of one Setup call.
+type tenant struct {
+	certManager *cert.Manager // Certificate inspection service
+	syncManager *sync.Manager // Certificate/config operations
+	options     types.Options // Configuration options
+	logger      *utils.Logger // Logger for consistent output
+}
+
+// registered maps each app to the routes manager that registered its routes,
+// so later Setup calls add their tenant instead of binding the routes again.
+var (
+	registeredMu gosync.Mutex
+	registered   = map[*pocketbase.PocketBase]*Manager{}
+)
+
+// NewManager creates a new routes manager.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance
+//   - certManager: Certificate manager for inspecting certificates
+//   - syncManager: Sync manager for certificate/config operations
+//   - options: Configuration options
+//   - logger: Logger instance
+//
+// RETURNS:
+// - Manager instance ready for route setup
+func NewManager(app *pocketbase.PocketBase, certManager *cert.Manager, syncManager *sync.Manager,
+	options types.Options, logger *utils.Logger) *Manager {
+	return &Manager{
+		app: app,
+		tenants: []tenant{{
+			certManager: certManager,
+			syncManager: syncManager,
+			options:     options,
+			logger:      logger,
+		}},
+	}
+}
+
+// SetupRoutes registers the pb-nebula routes on PocketBase's router.
+// Routes are bound on serve, so this is a no-op for non-serve commands.
+// With several Setup calls (multi-tenant), the first registers the routes and
+// later calls add their tenant to them.
+//
+// ROUTES:
+// - GET /api/nebula/certificate: Decoded certificate of the authenticated host
+// - POST /api/nebula/hosts/{id}/rotate-keys: Re-key a single host (superuser)
+// - POST /api/nebula/networks/{id}/rotate-keys: Re-key every host in a network (superuser)
+// - GET /api/nebula/reachability: Simulate whether one host can reach another (superuser)
+// - GET /api/nebula/networks/{id}/firewall-audit: Lint the firewall of every host in a network (superuser)
+// - GET /api/nebula/certificate-audit: Find hosts with credentials Nebula would reject (superuser)
+// - POST /api/nebula/regenerate: Force-regenerate configs or certificates of a host, network or everything (superuser)
+// - GET /api/nebula/queue: Regeneration queue depth and worker state (superuser)
+// - ?tenant=COLLECTION (superuser routes): Host collection of the tenant, required with several tenants
+//
+// RETURNS:
+// - nil on successful route registration
+// - error if route setup fails
+func (rm *Manager) SetupRoutes() error {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	if first, ok := registered[rm.app]; ok {
+		first.addTenants(rm.tenants)
+		return nil
+	}
+	registered[rm.app] = rm
+
+	rm.app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.GET("/api/nebula/certificate", rm.handleHostCertificate).
+			Bind(apis.RequireAuth(rm.hostCollections()...))
+
+		admin := se.Router.Group("/api/nebula")
+		admin.Bind(apis.RequireSuperuserAuth())
+		admin.POST("/hosts/{id}/rotate-keys", rm.handleRotateHostKeys)
+		admin.POST("/networks/{id}/rotate-keys", rm.handleRotateNetworkKeys)
+		admin.GET("/reachability", rm.handleReachability)
+		admin.GET("/networks/{id}/firewall-audit", rm.handleFirewallAudit)
+		admin.GET("/certificate-audit", rm.handleCertificateAudit)
+		admin.POST("/regenerate", rm.handleRegenerate)
+		admin.GET("/queue", rm.handleQueueStats)
+
+		return se.Next()
+	})
+
+	return nil
+}
+
+// addTenants adds tenants of a later Setup call, skipping host collections
+// that are already registered.
+func (rm *Manager) addTenants(tenants []tenant) {
+	for _, added := range tenants {
+		if _, ok := rm.findTenant(added.options.HostCollectionName); !ok {
+			rm.tenants = append(rm.tenants, added)
+		}
+	}
+}
+
+// findTenant returns the tenant using the given host collection.
+func (rm *Manager) findTenant(hostCollection string) (*tenant, bool) {
+	for i := range rm.tenants {
+		if rm.tenants[i].options.HostCollectionName == hostCollection {
+			return &rm.tenants[i], true
+		}
+	}
+	return nil, false
+}
+
+// hostCollections lists the host collections of every tenant.
+func (rm *Manager) hostCollections() []string {
+	names := make([]string, 0, len(rm.tenants))
+	for _, t := range rm.tenants {
+		names = append(names, t.options.HostCollectionName)
+	}
+	return names
+}
+
+// requestTenant resolves the tenant selected with ?tenant= as a 400 error response.
+// Without the parameter, the only tenant is used; several tenants require it.
+func (rm *Manager) requestTenant(e *core.RequestEvent) (*tenant, error) {
+	selected := e.Request.URL.Query().Get("tenant")
+	if selected == "" && len(rm.tenants) == 1 {
+		return &rm.tenants[0], nil
+	}
+	if t, ok := rm.findTenant(selected); ok {
+		return t, nil
+	}
+
+	names := strings.Join(rm.hostCollections(), ", ")
+	if selected == "" {
+		return nil, e.BadRequestError(fmt.Sprintf("Several tenants are set up, select one with ?tenant= (%s).", names), nil)
+	}
+	return nil, e.BadRequestError(fmt.Sprintf("Unknown tenant %q, expected one of: %s.", selected, names), nil)
+}
+
+// handleHostCertificate returns the decoded certificate of the authenticated host.
+//
+// RESPONSE:
+// - 200: cert.CertificateInfo as JSON
+// - 404: Host has no certificate yet
+// - 500: Stored certificate cannot be parsed
+func (rm *Manager) handleHostCertificate(e *core.RequestEvent) error {
+	t, ok := rm.findTenant(e.Auth.Collection().Name)
+	if !ok {
+		return e.ForbiddenError("Not a host record.", nil)
+	}
+
+	certPEM := e.Auth.GetString("certificate")
+	if certPEM == "" {
+		return e.NotFoundError("Host has no certificate.", nil)
+	}
+
+	info, err := t.certManager.ParseCertificate(certPEM)
+	if err != nil {
+		t.logger.Warning("Failed to parse certificate for host %s: %v", e.Auth.Id, err)
+		return e.InternalServerError("Failed to parse host certificate.", err)
+	}
+
+	return e.JSON(http.StatusOK, info)
+}
+
+// handleRotateHostKeys generates a new key pair for a host and blocklists its old certificate.
+//
+// RESPONSE:
+// - 200: sync.KeyRotationResult as JSON
+// - 400: Rotation failed
+// - 404: Host not found
+// - 500: Keys rotated, but the network configs carrying the blocklist weren't regenerated
+func (rm *Manager) handleRotateHostKeys(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	hostID := e.Request.PathValue("id")
+	if _, err := rm.app.FindRecordById(t.options.HostCollectionName, hostID); err != nil {
+		return e.NotFoundError("Host not found.", err)
+	}
+
+	result, err := t.syncManager.RotateHostKeys(hostID)
+	if errors.Is(err, sync.ErrConfigsNotRegenerated) {
+		t.logger.Error("Rotated keys for host %s, but: %v", hostID, err)
+		return e.InternalServerError(err.Error(), nil)
+	}
+	if err != nil {
+		t.logger.Error("Failed to rotate keys for host %s: %v", hostID, err)
+		return e.BadRequestError("Failed to rotate host keys: "+err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, result)
+}
+
+// handleRotateNetworkKeys generates new key pairs for every host in a network.
+// The rotation is all-or-nothing: a failing host leaves every host unchanged.
+//
+// RESPONSE:
+// - 200: {"rotated": []sync.KeyRotationResult}
+// - 400: Rotation failed (no host was changed)
+// - 404: Network not found
+// - 500: Keys rotated, but the network configs carrying the blocklist weren't regenerated
+func (rm *Manager) handleRotateNetworkKeys(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	networkID := e.Request.PathValue("id")
+	if _, err := rm.app.FindRecordById(t.options.NetworkCollectionName, networkID); err != nil {
+		return e.NotFoundError("Network not found.", err)
+	}
+
+	results, err := t.syncManager.RotateNetworkKeys(networkID)
+	if errors.Is(err, sync.ErrConfigsNotRegenerated) {
+		t.logger.Error("Rotated keys in network %s, but: %v", networkID, err)
+		return e.InternalServerError(err.Error(), nil)
+	}
+	if err != nil {
+		t.logger.Error("Failed to rotate keys in network %s: %v", networkID, err)
+		return e.BadRequestError("Failed to rotate network keys: "+err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"rotated": results,
+	})
+}
+
+// handleReachability answers "can host A reach host B on PROTO/PORT?" from the generated firewall rules.
+//
+// QUERY PARAMETERS:
+// - from, to: Host record IDs (A initiates, B receives)
+// - proto: tcp, udp or icmp
+// - port: Destination port (omit for icmp)
+//
+// RESPONSE:
+// - 200: firewall.Verdict as JSON (allowed or not, with the matching rules)
+// - 400: Invalid query or a host has no certificate/config yet
+// - 404: Host not found
+func (rm *Manager) handleReachability(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	params := e.Request.URL.Query()
+	query := sync.ReachabilityQuery{
+		FromHostID: params.Get("from"),
+		ToHostID:   params.Get("to"),
+		Proto:      params.Get("proto"),
+	}
+
+	if rawPort := params.Get("port"); rawPort != "" {
+		port, err := strconv.Atoi(rawPort)
+		if err != nil {
+			return e.BadRequestError("port must be a number.", err)
+		}
+		query.Port = port
+	}
+
+	for _, hostID := range []string{query.FromHostID, query.ToHostID} {
+		if _, err := rm.app.FindRecordById(t.options.HostCollectionName, hostID); err != nil {
+			return e.NotFoundError("Host not found.", err)
+		}
+	}
+
+	verdict, err := t.syncManager.CheckReachability(query)
+	if err != nil {
+		return e.BadRequestError("Failed to check reachability: "+err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, verdict)
+}
+
+// handleFirewallAudit lints the effective firewall of every host in a network.
+//
+// RESPONSE:
+// - 200: {"hosts": []sync.FirewallAudit}
+// - 404: Network not found
+// - 500: Hosts could not be loaded
+func (rm *Manager) handleFirewallAudit(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	networkID := e.Request.PathValue("id")
+	if _, err := rm.app.FindRecordById(t.options.NetworkCollectionName, networkID); err != nil {
+		return e.NotFoundError("Network not found.", err)
+	}
+
+	audits, err := t.syncManager.AuditNetworkFirewall(networkID)
+	if err != nil {
+		t.logger.Error("Failed to audit firewall of network %s: %v", networkID, err)
+		return e.InternalServerError("Failed to audit network firewall.", err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"hosts": audits,
+	})
+}
+
+// handleCertificateAudit verifies stored host credentials against their CA.
+//
+// QUERY PARAMETERS:
+// - network: Network record ID (omit to audit every host)
+//
+// RESPONSE:
+// - 200: {"issues": []sync.HostCertIssue} (empty if every host is valid)
+// - 404: Network not found
+// - 500: Hosts could not be loaded
+func (rm *Manager) handleCertificateAudit(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	networkID := e.Request.URL.Query().Get("network")
+	if networkID != "" {
+		if _, err := rm.app.FindRecordById(t.options.NetworkCollectionName, networkID); err != nil {
+			return e.NotFoundError("Network not found.", err)
+		}
+	}
+
+	issues, err := t.syncManager.AuditHostCertificates(networkID)
+	if err != nil {
+		t.logger.Error("Failed to audit host certificates: %v", err)
+		return e.InternalServerError("Failed to audit host certificates.", err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"issues": issues,
+	})
+}
+
+// handleRegenerate force-regenerates configs, or re-signs certificates, in bulk.
+// Host failures don't fail the request - they are listed in the report.
+//
+// REQUEST BODY:
+// - host_id / network_id: Scope (omit both and set "all": true for every host)
+// - resign: Re-sign certificates too
+// - dry_run: Generate and validate without saving
+//
+// RESPONSE:
+// - 200: sync.RegenerateReport as JSON
+// - 400: Invalid body or scope
+// - 404: Host or network not found
+func (rm *Manager) handleRegenerate(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	body := struct {
+		sync.RegenerateRequest
+		All bool `json:"all"`
+	}{}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("Invalid request body.", err)
+	}
+
+	request := body.RegenerateRequest
+	switch {
+	case request.HostID != "" && request.NetworkID != "":
+		return e.BadRequestError("Select a host or a network, not both.", nil)
+	case request.HostID == "" && request.NetworkID == "" && !body.All:
+		return e.BadRequestError("Select host_id, network_id or all.", nil)
+	case request.HostID != "":
+		if _, err := rm.app.FindRecordById(t.options.HostCollectionName, request.HostID); err != nil {
+			return e.NotFoundError("Host not found.", err)
+		}
+	case request.NetworkID != "":
+		if _, err := rm.app.FindRecordById(t.options.NetworkCollectionName, request.NetworkID); err != nil {
+			return e.NotFoundError("Network not found.", err)
+		}
+	}
+
+	report, err := t.syncManager.Regenerate(request, func(done, total int, result sync.RegenerateResult) {
+		if result.Error != "" {
+			t.logger.Warning("[%d/%d] Failed to regenerate host %s: %s", done, total, result.Hostname, result.Error)
+			return
+		}
+		t.logger.Config("[%d/%d] Regenerated host %s", done, total, result.Hostname)
+	})
+	if report == nil {
+		return e.BadRequestError("Failed to regenerate: "+err.Error(), nil)
+	}
+
+	return e.JSON(http.StatusOK, report)
+}
+
+// handleQueueStats reports the regeneration queue for monitoring.
+//
+// RESPONSE:
+// - 200: sync.QueueStats as JSON
+// - 500: Queue collection could not be counted
+func (rm *Manager) handleQueueStats(e *core.RequestEvent) error {
+	t, err := rm.requestTenant(e)
+	if err != nil {
+		return err
+	}
+
+	stats, err := t.syncManager.RegenerationQueueStats()
+	if err != nil {
+		t.logger.Error("Failed to read regeneration queue: %v", err)
+		return e.InternalServerError("Failed to read regeneration queue.", err)
+	}
+
+	return e.JSON(http.StatusOK, stats)
+}
+
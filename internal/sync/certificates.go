@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/skeeeon/pb-nebula/internal/cert"
+)
+
+// HostCertIssue describes a host whose stored credentials Nebula would reject.
+type HostCertIssue struct {
+	HostID    string              `json:"host_id"`    // Host record ID
+	Hostname  string              `json:"hostname"`   // Host name
+	NetworkID string              `json:"network_id"` // Network the host references
+	Status    cert.HostCertStatus `json:"status"`     // missing, invalid, mismatch, expired or orphaned
+	Problem   string              `json:"problem"`    // What is wrong with the host
+}
+
+// AuditHostCertificates verifies the stored credentials of hosts against Nebula's CA pool.
+// Certificates are only checked when issued, so database edits or restores can
+// leave hosts that fail to start - this sweep finds them.
+//
+// CHECKS PER HOST:
+// - The network and its CA still exist (orphaned otherwise)
+// - certificate is signed by the stored ca_certificate and unexpired (see cert.Manager.VerifyHostCertificate)
+// - private_key matches certificate
+// - ca_certificate is still the network CA's certificate
+//
+// PARAMETERS:
+//   - networkID: Database ID of the network to audit (empty audits every host)
+//
+// RETURNS:
+// - []HostCertIssue with one entry per broken host (empty if all hosts are valid)
+// - error if the network or hosts cannot be loaded
+func (sm *Manager) AuditHostCertificates(networkID string) ([]HostCertIssue, error) {
+	var hosts []*core.Record
+	var err error
+	if networkID != "" {
+		if _, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, networkID); err != nil {
+			return nil, fmt.Errorf("network not found: %w", err)
+		}
+		hosts, err = sm.app.FindAllRecords(sm.options.HostCollectionName,
+			dbx.HashExp{"network_id": networkID})
+	} else {
+		hosts, err = sm.app.FindAllRecords(sm.options.HostCollectionName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find hosts: %w", err)
+	}
+
+	issues := []HostCertIssue{}
+	for _, host := range hosts {
+		status, err := sm.verifyHostCertificate(host)
+		if status == cert.HostCertValid {
+			continue
+		}
+		issues = append(issues, HostCertIssue{
+			HostID:    host.Id,
+			Hostname:  host.GetString("hostname"),
+			NetworkID: host.GetString("network_id"),
+			Status:    status,
+			Problem:   err.Error(),
+		})
+	}
+
+	return issues, nil
+}
+
+// verifyHostCertificate checks a single host record (see AuditHostCertificates).
+func (sm *Manager) verifyHostCertificate(host *core.Record) (cert.HostCertStatus, error) {
+	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, host.GetString("network_id"))
+	if err != nil {
+		return cert.HostCertOrphaned, fmt.Errorf("network %q not found", host.GetString("network_id"))
+	}
+
+	ca, err := sm.app.FindRecordById(sm.options.CACollectionName, network.GetString("ca_id"))
+	if err != nil {
+		return cert.HostCertOrphaned, fmt.Errorf("CA %q of network %s not found", network.GetString("ca_id"), network.GetString("name"))
+	}
+
+	caCertPEM := host.GetString("ca_certificate")
+	status, err := sm.certManager.VerifyHostCertificate(caCertPEM, host.GetString("certificate"), host.GetString("private_key"))
+	if status != cert.HostCertValid {
+		return status, err
+	}
+
+	// Signed by the stored CA, but that CA must also be the one the network uses now
+	if caCertPEM != ca.GetString("certificate") {
+		return cert.HostCertMismatch, fmt.Errorf("stored CA certificate differs from CA %s of network %s",
+			ca.GetString("name"), network.GetString("name"))
+	}
+
+	return cert.HostCertValid, nil
+}
+
+// setupCertificateSweep audits host certificates when the server starts.
+// Broken hosts are logged, not repaired - fixing them needs a deliberate
+// re-sign or key rotation.
+func (sm *Manager) setupCertificateSweep() {
+	sm.app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		issues, err := sm.AuditHostCertificates("")
+		if err != nil {
+			sm.logger.Warning("Failed to audit host certificates: %v", err)
+			return se.Next()
+		}
+
+		for _, issue := range issues {
+			sm.logger.Warning("Host %s (%s) has %s credentials: %s", issue.Hostname, issue.HostID, issue.Status, issue.Problem)
+		}
+		if len(issues) > 0 {
+			sm.logger.Warning("%d hosts have credentials Nebula would reject", len(issues))
+		}
+
+		return se.Next()
+	})
+}
@@ -0,0 +1,307 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/skeeeon/pb-nebula/internal/cert"
+	"github.com/skeeeon/pb-nebula/internal/utils"
+)
+
+// Doctor checks, reported in DoctorFinding.Check
+const (
+	CheckOutsideCIDR      = "outside_cidr"       // Host IP is not in its network's CIDR
+	CheckDuplicateIP      = "duplicate_ip"       // Overlay IP used by more than one host
+	CheckLighthouseNoAddr = "lighthouse_no_addr" // Lighthouse without a public endpoint
+	CheckStaleConfig      = "stale_config"       // Stored config differs from a fresh generation
+	CheckCertGroups       = "cert_groups"        // Certificate groups differ from the groups field
+	CheckInactiveNetwork  = "inactive_network"   // Active host in an inactive network
+	CheckHostCertificate  = "host_certificate"   // Host credentials Nebula would reject (see AuditHostCertificates)
+	CheckHostExpiry       = "host_expiry"        // Host certificate expires within the doctor window
+	CheckCAExpiry         = "ca_expiry"          // CA certificate expired or expires within the doctor window
+)
+
+// Doctor repair actions, reported in DoctorFinding.Repair
+const (
+	RepairResign           = "resign"     // Re-sign the host certificate and regenerate its config
+	RepairRegenerateConfig = "regenerate" // Regenerate the host config only
+)
+
+// doctorExpiryWindow is how far ahead the doctor warns about expiring certificates.
+const doctorExpiryWindow = 30 * 24 * time.Hour
+
+// DoctorFinding is a single consistency problem found by Doctor.
+type DoctorFinding struct {
+	Check      string `json:"check"`            // Which check failed (Check* constants)
+	Collection string `json:"collection"`       // Collection of the affected record
+	RecordID   string `json:"record_id"`        // Affected record ID
+	Name       string `json:"name"`             // Hostname or CA name
+	Problem    string `json:"problem"`          // What is wrong
+	Repair     string `json:"repair,omitempty"` // Repair* action, empty if it needs a human decision
+}
+
+// DoctorReport is the outcome of a Doctor run.
+type DoctorReport struct {
+	Findings     []DoctorFinding `json:"findings"`      // Problems found before any repair
+	Repaired     []string        `json:"repaired"`      // IDs of hosts regenerated by the repair
+	RepairErrors []string        `json:"repair_errors"` // Hosts the repair failed for
+	Remaining    []DoctorFinding `json:"remaining"`     // Problems left after repair (equal to Findings without repair)
+}
+
+// Doctor audits the whole database for records that are inconsistent with each
+// other or with their certificates, and optionally repairs what can be fixed by
+// regenerating hosts.
+//
+// CHECKS:
+// - Hosts outside their network CIDR, duplicate overlay IPs (across all networks)
+// - Lighthouses without a public endpoint
+// - Configs that differ from a fresh generation (a relevant change was missed or a
+//   network-wide regeneration failed) or whose fresh generation Nebula would reject
+// - Certificates whose groups differ from the groups field
+// - Active hosts in inactive networks
+// - Broken host credentials, expired or soon expiring host and CA certificates
+//
+// REPAIR:
+// Hosts with certificate problems are re-signed (keeping their key when possible),
+// hosts with stale configs are regenerated. Everything else needs a human decision
+// (e.g. which of two hosts keeps an IP) and is only reported.
+//
+// PARAMETERS:
+//   - repair: Regenerate repairable hosts after the audit
+//
+// RETURNS:
+// - DoctorReport with the findings and repair results
+// - error if records cannot be loaded
+func (sm *Manager) Doctor(repair bool) (*DoctorReport, error) {
+	findings, err := sm.diagnose()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DoctorReport{
+		Findings:     findings,
+		Repaired:     []string{},
+		RepairErrors: []string{},
+		Remaining:    findings,
+	}
+	if !repair {
+		return report, nil
+	}
+
+	repaired, err := sm.repairHosts(findings)
+	report.Repaired = repaired
+	if err != nil {
+		report.RepairErrors = utils.SplitJoinedError(err)
+	}
+
+	// Audit again so the report shows what the repair couldn't fix
+	if report.Remaining, err = sm.diagnose(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// diagnose runs every doctor check and returns the findings.
+func (sm *Manager) diagnose() ([]DoctorFinding, error) {
+	hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find hosts: %w", err)
+	}
+	networks, err := sm.app.FindAllRecords(sm.options.NetworkCollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find networks: %w", err)
+	}
+	cas, err := sm.app.FindAllRecords(sm.options.CACollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find CAs: %w", err)
+	}
+
+	networksByID := make(map[string]*core.Record, len(networks))
+	for _, network := range networks {
+		networksByID[network.Id] = network
+	}
+
+	findings := []DoctorFinding{}
+	hostFinding := func(host *core.Record, check, repair, format string, args ...any) {
+		findings = append(findings, DoctorFinding{
+			Check:      check,
+			Collection: sm.options.HostCollectionName,
+			RecordID:   host.Id,
+			Name:       host.GetString("hostname"),
+			Problem:    fmt.Sprintf(format, args...),
+			Repair:     repair,
+		})
+	}
+
+	hostsByIP := map[string][]*core.Record{}
+	for _, host := range hosts {
+		hostsByIP[host.GetString("overlay_ip")] = append(hostsByIP[host.GetString("overlay_ip")], host)
+	}
+
+	now := sm.certManager.Now() // Same clock as certificate verification
+	for _, host := range hosts {
+		network := networksByID[host.GetString("network_id")]
+
+		if network != nil {
+			if err := sm.ipamManager.ValidateHostIP(host.GetString("overlay_ip"), network.Id); err != nil {
+				hostFinding(host, CheckOutsideCIDR, "", "%v", err)
+			}
+			if host.GetBool("active") && !network.GetBool("active") {
+				hostFinding(host, CheckInactiveNetwork, "", "network %s is inactive", network.GetString("name"))
+			}
+		}
+
+		if duplicates := hostsByIP[host.GetString("overlay_ip")]; len(duplicates) > 1 {
+			others := []string{}
+			for _, other := range duplicates {
+				if other.Id != host.Id {
+					others = append(others, other.GetString("hostname"))
+				}
+			}
+			hostFinding(host, CheckDuplicateIP, "", "overlay IP %s is also used by %v", host.GetString("overlay_ip"), others)
+		}
+
+		if host.GetBool("is_lighthouse") {
+			additional, _ := jsonStringArray(host, "public_endpoints")
+			if host.GetString("public_host_port") == "" && len(additional) == 0 {
+				hostFinding(host, CheckLighthouseNoAddr, "", "lighthouse has no public_host_port or public_endpoints")
+			}
+		}
+
+		// Credentials first - the remaining checks need a usable certificate
+		status, err := sm.verifyHostCertificate(host)
+		if status != cert.HostCertValid {
+			repair := RepairResign
+			if status == cert.HostCertOrphaned {
+				repair = ""
+			}
+			hostFinding(host, CheckHostCertificate, repair, "%s: %v", status, err)
+			continue
+		}
+
+		info, err := sm.certManager.ParseCertificate(host.GetString("certificate"))
+		if err != nil {
+			hostFinding(host, CheckHostCertificate, RepairResign, "%v", err)
+			continue
+		}
+		if info.NotAfter.Sub(now) < doctorExpiryWindow {
+			hostFinding(host, CheckHostExpiry, RepairResign, "certificate expires %s", info.NotAfter.Format(time.RFC3339))
+		}
+
+		groups, err := jsonStringArray(host, "groups")
+		if err != nil {
+			hostFinding(host, CheckCertGroups, "", "%v", err)
+		} else if !sameStrings(groups, info.Groups) {
+			hostFinding(host, CheckCertGroups, RepairResign, "certificate groups %v differ from groups field %v", info.Groups, groups)
+		}
+
+		if stale, err := sm.configStale(host); err != nil {
+			hostFinding(host, CheckStaleConfig, "", "config cannot be generated: %v", err)
+		} else if stale {
+			hostFinding(host, CheckStaleConfig, RepairRegenerateConfig, "stored config differs from a fresh generation")
+		}
+	}
+
+	for _, ca := range cas {
+		info, err := sm.certManager.ParseCertificate(ca.GetString("certificate"))
+		if err != nil {
+			findings = append(findings, DoctorFinding{
+				Check:      CheckCAExpiry,
+				Collection: sm.options.CACollectionName,
+				RecordID:   ca.Id,
+				Name:       ca.GetString("name"),
+				Problem:    err.Error(),
+			})
+			continue
+		}
+		if info.NotAfter.Sub(now) < doctorExpiryWindow {
+			findings = append(findings, DoctorFinding{
+				Check:      CheckCAExpiry,
+				Collection: sm.options.CACollectionName,
+				RecordID:   ca.Id,
+				Name:       ca.GetString("name"),
+				Problem:    fmt.Sprintf("CA certificate expires %s", info.NotAfter.Format(time.RFC3339)),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// configStale reports whether a host's stored config differs from a fresh generation.
+// A fresh config Nebula would reject is an error, so hosts a network-wide
+// regeneration failed on are reported with the reason.
+func (sm *Manager) configStale(host *core.Record) (bool, error) {
+	params, err := sm.hostConfigParams(host)
+	if err != nil {
+		return false, err
+	}
+
+	configYAML, err := sm.configGen.GenerateHostConfig(params)
+	if err != nil {
+		return false, err
+	}
+	if err := sm.verifyHostConfig(configYAML, false); err != nil {
+		return false, err
+	}
+
+	return configYAML != host.GetString("config_yaml"), nil
+}
+
+// repairHosts regenerates every host with a repairable finding, once per host.
+// A host needing a new certificate is re-signed, which regenerates its config too.
+func (sm *Manager) repairHosts(findings []DoctorFinding) ([]string, error) {
+	actions := map[string]string{}
+	order := []string{}
+	for _, finding := range findings {
+		if finding.Repair == "" || finding.Collection != sm.options.HostCollectionName {
+			continue
+		}
+		if _, ok := actions[finding.RecordID]; !ok {
+			order = append(order, finding.RecordID)
+		}
+		if actions[finding.RecordID] != RepairResign {
+			actions[finding.RecordID] = finding.Repair
+		}
+	}
+
+	repaired := []string{}
+	var failures []error
+	for _, hostID := range order {
+		host, err := sm.app.FindRecordById(sm.options.HostCollectionName, hostID)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("host %s: %w", hostID, err))
+			continue
+		}
+
+		if actions[hostID] == RepairResign {
+			err = sm.resignHostCertAndConfig(host)
+		} else {
+			err = sm.generateHostConfig(host)
+		}
+		if err == nil {
+			err = sm.app.Save(host)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("host %s: %w", host.GetString("hostname"), err))
+			continue
+		}
+
+		sm.logger.Success("Repaired host %s (%s)", host.GetString("hostname"), actions[hostID])
+		repaired = append(repaired, hostID)
+	}
+
+	return repaired, errors.Join(failures...)
+}
+
+// sameStrings reports whether two string lists contain the same values, ignoring order.
+func sameStrings(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/skeeeon/pb-nebula/internal/config"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
+)
+
+// FirewallAudit lists the firewall lint findings of a single host.
+type FirewallAudit struct {
+	HostID   string             `json:"host_id"`         // Host record ID
+	Hostname string             `json:"hostname"`        // Host name
+	Findings []firewall.Finding `json:"findings"`        // Problems in the host's effective firewall
+	Error    string             `json:"error,omitempty"` // Why the host couldn't be linted (e.g., broken rule set)
+}
+
+// AuditNetworkFirewall lints the effective firewall of every host in a network.
+// Findings are computed fresh, so groups added to or removed from other hosts
+// since a host's config was last generated are taken into account.
+//
+// PARAMETERS:
+//   - networkID: Database ID of the network to audit
+//
+// RETURNS:
+// - []FirewallAudit with one entry per host (hosts that can't be linted carry an error)
+// - error if the network or its hosts cannot be loaded
+func (sm *Manager) AuditNetworkFirewall(networkID string) ([]FirewallAudit, error) {
+	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, networkID)
+	if err != nil {
+		return nil, fmt.Errorf("network not found: %w", err)
+	}
+
+	hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+		dbx.HashExp{"network_id": network.Id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find hosts in network: %w", err)
+	}
+
+	audits := make([]FirewallAudit, 0, len(hosts))
+	for _, host := range hosts {
+		audit := FirewallAudit{
+			HostID:   host.Id,
+			Hostname: host.GetString("hostname"),
+			Findings: []firewall.Finding{},
+		}
+
+		params, err := sm.hostConfigParams(host)
+		if err == nil {
+			var findings []firewall.Finding
+			if findings, err = sm.lintHostFirewall(params); err == nil {
+				audit.Findings = findings
+			}
+		}
+		if err != nil {
+			audit.Error = err.Error()
+		}
+
+		audits = append(audits, audit)
+	}
+
+	return audits, nil
+}
+
+// lintHostFirewall lints the effective firewall of a host against its network's groups.
+func (sm *Manager) lintHostFirewall(params config.HostConfigParams) ([]firewall.Finding, error) {
+	outbound, inbound, err := sm.configGen.EffectiveFirewall(params)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := sm.getNetworkGroups(params.Network.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network groups: %w", err)
+	}
+
+	return firewall.Lint(outbound, inbound, groups), nil
+}
@@ -4,12 +4,15 @@ package sync
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"slices"
 
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/skeeeon/pb-nebula/internal/cert"
 	"github.com/skeeeon/pb-nebula/internal/config"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
 	"github.com/skeeeon/pb-nebula/internal/ipam"
 	"github.com/skeeeon/pb-nebula/internal/types"
 	"github.com/skeeeon/pb-nebula/internal/utils"
@@ -23,16 +26,22 @@ import (
 // - Automatic generation on create/update
 // - Network updates trigger regeneration of all host configs
 //
+// ATOMIC WRITES:
+// - Certificates and configs are generated inside the record's own save, so a host
+//   or CA is never persisted without its credentials
+// - Synchronous network-wide regenerations save every host in one transaction
+//
 // RECURSION PREVENTION:
-// - Skip update hooks triggered by our own saves during creation
+// - Generation modifies the record being saved instead of saving it again
 // - Only regenerate configs when meaningful fields change
 type Manager struct {
-	app         *pocketbase.PocketBase // PocketBase application instance
-	certManager *cert.Manager          // Certificate generation service
-	configGen   *config.Generator      // Config generation service
-	ipamManager *ipam.Manager          // IP validation service
-	options     types.Options          // Configuration options
-	logger      *utils.Logger          // Logger for consistent output
+	app         core.App           // PocketBase application (a transaction app for scoped copies, see withApp)
+	certManager *cert.Manager      // Certificate generation service
+	configGen   *config.Generator  // Config generation service
+	ipamManager *ipam.Manager      // IP validation service
+	options     types.Options      // Configuration options
+	logger      *utils.Logger      // Logger for consistent output
+	queue       *regenerationQueue // Background regeneration of network fan-outs
 }
 
 // NewManager creates a new sync manager with all required dependencies.
@@ -49,7 +58,7 @@ type Manager struct {
 // - Manager instance ready for hook setup
 func NewManager(app *pocketbase.PocketBase, certManager *cert.Manager, configGen *config.Generator,
 	ipamManager *ipam.Manager, options types.Options, logger *utils.Logger) *Manager {
-	return &Manager{
+	sm := &Manager{
 		app:         app,
 		certManager: certManager,
 		configGen:   configGen,
@@ -57,14 +66,46 @@ func NewManager(app *pocketbase.PocketBase, certManager *cert.Manager, configGen
 		options:     options,
 		logger:      logger,
 	}
+	sm.queue = newRegenerationQueue(sm)
+	return sm
+}
+
+// NewStandaloneManager creates a manager with its own certificate manager, config
+// generator and IPAM manager, for the Go APIs and CLI commands that run outside Setup.
+// PocketBase must be bootstrapped before the manager is used.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance
+//   - options: Configuration options (as passed to Setup)
+//   - logger: Logger instance
+//
+// RETURNS:
+// - Manager instance (without hooks)
+func NewStandaloneManager(app *pocketbase.PocketBase, options types.Options, logger *utils.Logger) *Manager {
+	return NewManager(app, cert.NewManager(options.CertificateBackdate), config.NewGenerator(),
+		ipam.NewManager(app, options), options, logger)
+}
+
+// withApp returns a copy of the manager that reads and writes through app.
+// Hooks running inside a save use it with the event's app, so generation sees
+// records written earlier in the same transaction and its writes join it.
+func (sm *Manager) withApp(app core.App) *Manager {
+	scoped := *sm
+	scoped.app = app
+	return &scoped
 }
 
 // SetupHooks registers PocketBase event hooks for real-time Nebula synchronization.
 //
 // HOOK CATEGORIES:
 // - CA hooks: Handle CA creation
+// - Template hooks: Validate templates and regenerate configs that use them
 // - Network hooks: Handle network lifecycle and validation
+// - Policy hooks: Validate firewall policies and regenerate configs of their network
+// - Rule set hooks: Validate and version firewall rule sets, regenerate configs of referencing hosts
 // - Host hooks: Handle host lifecycle, certificate generation, and config generation
+// - Certificate sweep: Log hosts with broken credentials when the server starts
+// - Regeneration queue: Regenerate network fan-outs in the background while serving
 //
 // RETURNS:
 // - nil on successful hook registration
@@ -74,8 +115,13 @@ func (sm *Manager) SetupHooks() error {
 
 	// Setup hooks for each collection type
 	sm.setupCAHooks()
+	sm.setupTemplateHooks()
 	sm.setupNetworkHooks()
+	sm.setupPolicyHooks()
+	sm.setupRuleSetHooks()
 	sm.setupHostHooks()
+	sm.setupCertificateSweep()
+	sm.setupRegenerationQueue()
 
 	sm.logger.Success("PocketBase hooks configured for Nebula sync")
 
@@ -85,10 +131,47 @@ func (sm *Manager) SetupHooks() error {
 // setupCAHooks registers hooks for CA lifecycle.
 //
 // CA EVENT HANDLING:
-// - Creation: Generate CA certificate and keys automatically after record is saved
+// - Validation: Validate constraint fields before creation, freeze them once generated
+// - Creation: Generate CA certificate and keys as part of the insert
 func (sm *Manager) setupCAHooks() {
-	// CA creation - generate certificate automatically
-	sm.app.OnRecordAfterCreateSuccess().BindFunc(func(e *core.RecordEvent) error {
+	// CA validation - constraints must be valid before the certificate is generated
+	sm.app.OnRecordCreateRequest().BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.CACollectionName {
+			return e.Next()
+		}
+
+		if err := sm.validateCAConstraints(e.Record); err != nil {
+			return err
+		}
+
+		return e.Next()
+	})
+
+	// CA constraints are embedded in the certificate and can't change afterwards
+	sm.app.OnRecordUpdateRequest().BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.CACollectionName {
+			return e.Next()
+		}
+
+		orig := e.Record.Original()
+		if orig != nil && orig.GetString("certificate") != "" {
+			for _, field := range []string{"allowed_networks", "allowed_groups"} {
+				before, _ := jsonStringArray(orig, field)
+				after, err := jsonStringArray(e.Record, field)
+				if err != nil {
+					return err
+				}
+				if !slices.Equal(before, after) {
+					return fmt.Errorf("%s cannot be changed after the CA certificate is generated", field)
+				}
+			}
+		}
+
+		return e.Next()
+	})
+
+	// CA creation - generate certificate before the insert, so a failure leaves no CA behind
+	sm.app.OnRecordCreateExecute().BindFunc(func(e *core.RecordEvent) error {
 		if e.Record.Collection().Name != sm.options.CACollectionName {
 			return e.Next()
 		}
@@ -106,20 +189,112 @@ func (sm *Manager) setupCAHooks() {
 			return fmt.Errorf("failed to generate CA certificate: %w", err)
 		}
 
-		if err := sm.app.Save(e.Record); err != nil {
-			return fmt.Errorf("failed to save CA record: %w", err)
+		if err := validateGenerated(e); err != nil {
+			return err
+		}
+
+		if err := e.Next(); err != nil {
+			return err
 		}
 
 		sm.logger.Success("Generated CA certificate for %s", e.Record.GetString("name"))
 
+		return nil
+	})
+}
+
+// setupTemplateHooks registers hooks for config template validation and updates.
+//
+// TEMPLATE EVENT HANDLING:
+// - Validation: Templates must render valid YAML before creation/update
+// - Updates: Regenerate configs of every host using the template (directly or via its network)
+func (sm *Manager) setupTemplateHooks() {
+	validateTemplate := func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.TemplateCollectionName {
+			return e.Next()
+		}
+
+		if err := sm.configGen.ValidateTemplate(e.Record.GetString("content")); err != nil {
+			return fmt.Errorf("invalid config template: %w", err)
+		}
+
+		return e.Next()
+	}
+	sm.app.OnRecordCreateRequest().BindFunc(validateTemplate)
+	sm.app.OnRecordUpdateRequest().BindFunc(validateTemplate)
+
+	// Template updates - regenerate configs only if the content changed
+	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.TemplateCollectionName {
+			return e.Next()
+		}
+
+		orig := e.Record.Original()
+		if orig != nil && orig.GetString("content") == e.Record.GetString("content") {
+			return e.Next()
+		}
+
+		if !sm.shouldHandleEvent(sm.options.TemplateCollectionName, types.EventTypeTemplateUpdate) {
+			return e.Next()
+		}
+
+		sm.logger.Config("Template %s updated, regenerating host configs...", e.Record.GetString("name"))
+
+		hosts, err := sm.findTemplateHosts(e.Record.Id)
+		if err != nil {
+			sm.logger.Warning("Failed to find hosts using template %s: %v", e.Record.Id, err)
+			return e.Next()
+		}
+
+		sm.regenerateHostConfigs(hosts, "template "+e.Record.GetString("name"))
+
 		return e.Next()
 	})
 }
 
+// findTemplateHosts returns every host using a template, directly or through its network.
+func (sm *Manager) findTemplateHosts(templateID string) ([]*core.Record, error) {
+	hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+		dbx.HashExp{"template_id": templateID})
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := sm.app.FindAllRecords(sm.options.NetworkCollectionName,
+		dbx.HashExp{"template_id": templateID})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		seen[host.Id] = true
+	}
+
+	for _, network := range networks {
+		networkHosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+			dbx.HashExp{"network_id": network.Id})
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range networkHosts {
+			// Hosts with their own template don't use the network template
+			if seen[host.Id] || host.GetString("template_id") != "" {
+				continue
+			}
+			seen[host.Id] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts, nil
+}
+
 // setupNetworkHooks registers hooks for network lifecycle and validation.
 //
 // NETWORK EVENT HANDLING:
-// - Validation: Validate CIDR format before creation/update
+// - Validation: Validate CIDR format and firewall defaults/settings before creation/update
+// - Authorization: Only superusers may change fields distributed to every host (networkAdminFields)
 // - Updates: Regenerate configs for all hosts in network (only if CIDR changes)
 func (sm *Manager) setupNetworkHooks() {
 	// Network validation - validate CIDR before creation/update
@@ -137,6 +312,18 @@ func (sm *Manager) setupNetworkHooks() {
 			return fmt.Errorf("CIDR validation failed: %w", err)
 		}
 
+		if err := sm.validateConfigOverrides(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateFirewallDefaults(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateFirewallSettings(e.Record); err != nil {
+			return err
+		}
+
 		return e.Next()
 	})
 
@@ -145,6 +332,15 @@ func (sm *Manager) setupNetworkHooks() {
 			return e.Next()
 		}
 
+		// Hosts may update networks, but a revoked host must not unblock itself
+		if orig := e.Record.Original(); orig != nil && !e.HasSuperuserAuth() {
+			for _, field := range networkAdminFields {
+				if orig.GetString(field) != e.Record.GetString(field) {
+					return fmt.Errorf("only superusers can change %s", field)
+				}
+			}
+		}
+
 		cidr := e.Record.GetString("cidr_range")
 		if err := sm.ipamManager.ValidateCIDRFormat(cidr); err != nil {
 			return fmt.Errorf("invalid CIDR format: %w", err)
@@ -154,60 +350,298 @@ func (sm *Manager) setupNetworkHooks() {
 			return fmt.Errorf("CIDR validation failed: %w", err)
 		}
 
+		if err := sm.validateConfigOverrides(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateFirewallDefaults(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateFirewallSettings(e.Record); err != nil {
+			return err
+		}
+
 		return e.Next()
 	})
 
-	// Network updates - regenerate all host configs ONLY if meaningful fields changed
+	// Network updates - regenerate all host configs
 	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
 		if e.Record.Collection().Name != sm.options.NetworkCollectionName {
 			return e.Next()
 		}
 
-		// Only regenerate if CIDR changed (major network change)
-		// Other fields like name/description don't affect host configs
+		// Every update regenerates: the blocklist, firewall defaults and settings,
+		// config overrides, template and CIDR are rendered into host configs, and
+		// templates can use any other field (name, description, active).
 		if !sm.shouldHandleEvent(sm.options.NetworkCollectionName, types.EventTypeNetworkUpdate) {
 			return e.Next()
 		}
 
 		sm.logger.Info("Network updated, regenerating host configs...")
+		sm.regenerateNetworkConfigs(e.Record)
 
-		// Find all hosts in this network
-		hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
-			dbx.HashExp{"network_id": e.Record.Id})
-		if err != nil {
-			sm.logger.Warning("Failed to find hosts in network %s: %v", e.Record.Id, err)
+		return e.Next()
+	})
+}
+
+// networkAdminFields are the network fields only superusers may change.
+// They are rendered into every host config of the network, so a host could
+// otherwise remove its own fingerprint from the blocklist or open the network's firewall.
+var networkAdminFields = []string{
+	"blocklist", "config_overrides", "template_id",
+	"firewall_default_mode", "firewall_default_outbound", "firewall_default_inbound", "firewall_settings",
+}
+
+// setupPolicyHooks registers hooks for network firewall policies.
+//
+// POLICY EVENT HANDLING:
+// - Validation: Direction, target groups and rules must be valid before creation/update
+// - Changes: Regenerate configs of every host in the policy's network (create/update/delete)
+func (sm *Manager) setupPolicyHooks() {
+	validatePolicy := func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.PolicyCollectionName {
 			return e.Next()
 		}
 
-		// Regenerate config for each host
-		regenerated := 0
-		for _, host := range hosts {
-			if err := sm.generateHostConfig(host); err != nil {
-				sm.logger.Warning("Failed to regenerate config for host %s: %v", host.Id, err)
-				continue
+		if err := firewall.ValidatePolicy(*sm.recordToPolicyModel(e.Record)); err != nil {
+			return fmt.Errorf("invalid firewall policy: %w", err)
+		}
+
+		return e.Next()
+	}
+	sm.app.OnRecordCreateRequest().BindFunc(validatePolicy)
+	sm.app.OnRecordUpdateRequest().BindFunc(validatePolicy)
+
+	sm.app.OnRecordAfterCreateSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.PolicyCollectionName {
+			return e.Next()
+		}
+
+		if sm.shouldHandleEvent(sm.options.PolicyCollectionName, types.EventTypePolicyCreate) {
+			sm.logger.Config("Firewall policy %s created, regenerating host configs...", e.Record.GetString("name"))
+			sm.regeneratePolicyNetworkConfigs(e.Record.GetString("network_id"))
+		}
+
+		return e.Next()
+	})
+
+	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.PolicyCollectionName {
+			return e.Next()
+		}
+
+		if sm.shouldHandleEvent(sm.options.PolicyCollectionName, types.EventTypePolicyUpdate) {
+			sm.logger.Config("Firewall policy %s updated, regenerating host configs...", e.Record.GetString("name"))
+			sm.regeneratePolicyNetworkConfigs(e.Record.GetString("network_id"))
+
+			// Policy moved to another network - the old network loses its rules
+			if orig := e.Record.Original(); orig != nil && orig.GetString("network_id") != e.Record.GetString("network_id") {
+				sm.regeneratePolicyNetworkConfigs(orig.GetString("network_id"))
 			}
-			if err := sm.app.Save(host); err != nil {
-				sm.logger.Warning("Failed to save host %s: %v", host.Id, err)
-				continue
+		}
+
+		return e.Next()
+	})
+
+	sm.app.OnRecordAfterDeleteSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.PolicyCollectionName {
+			return e.Next()
+		}
+
+		if sm.shouldHandleEvent(sm.options.PolicyCollectionName, types.EventTypePolicyDelete) {
+			sm.logger.Config("Firewall policy %s deleted, regenerating host configs...", e.Record.GetString("name"))
+			sm.regeneratePolicyNetworkConfigs(e.Record.GetString("network_id"))
+		}
+
+		return e.Next()
+	})
+}
+
+// setupRuleSetHooks registers hooks for reusable firewall rule sets.
+//
+// RULE SET EVENT HANDLING:
+// - Validation: Rules must be valid before creation/update
+// - Versioning: version starts at 1 and is incremented whenever the rules change
+// - Updates: Regenerate configs of every host referencing the rule set
+// - Deletion: PocketBase unsets the reference on each host, whose update hook regenerates its config
+func (sm *Manager) setupRuleSetHooks() {
+	sm.app.OnRecordCreateRequest().BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.RuleSetCollectionName {
+			return e.Next()
+		}
+
+		if err := firewall.ValidateRuleSet(*sm.recordToRuleSetModel(e.Record)); err != nil {
+			return fmt.Errorf("invalid firewall rule set: %w", err)
+		}
+
+		e.Record.Set("version", 1)
+
+		return e.Next()
+	})
+
+	sm.app.OnRecordUpdateRequest().BindFunc(func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != sm.options.RuleSetCollectionName {
+			return e.Next()
+		}
+
+		if err := firewall.ValidateRuleSet(*sm.recordToRuleSetModel(e.Record)); err != nil {
+			return fmt.Errorf("invalid firewall rule set: %w", err)
+		}
+
+		// Version is maintained here, never taken from the request
+		if orig := e.Record.Original(); orig != nil {
+			version := orig.GetInt("version")
+			if ruleSetRulesChanged(orig, e.Record) {
+				version++
 			}
-			regenerated++
+			e.Record.Set("version", version)
+		}
+
+		return e.Next()
+	})
+
+	// Rule set updates - regenerate configs only if the rules changed
+	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.RuleSetCollectionName {
+			return e.Next()
+		}
+
+		orig := e.Record.Original()
+		if orig != nil && !ruleSetRulesChanged(orig, e.Record) {
+			return e.Next()
+		}
+
+		if !sm.shouldHandleEvent(sm.options.RuleSetCollectionName, types.EventTypeRuleSetUpdate) {
+			return e.Next()
+		}
+
+		sm.logger.Config("Firewall rule set %s updated to version %d, regenerating host configs...",
+			e.Record.GetString("name"), e.Record.GetInt("version"))
+
+		hosts, err := sm.findRuleSetHosts(e.Record.Id)
+		if err != nil {
+			sm.logger.Warning("Failed to find hosts using firewall rule set %s: %v", e.Record.Id, err)
+			return e.Next()
 		}
 
-		sm.logger.Success("Regenerated configs for %d/%d hosts in network %s", regenerated, len(hosts), e.Record.GetString("name"))
+		sm.regenerateHostConfigs(hosts, "firewall rule set "+e.Record.GetString("name"))
 
 		return e.Next()
 	})
 }
 
+// ruleSetRulesChanged reports whether the rules of a firewall rule set changed.
+func ruleSetRulesChanged(orig, record *core.Record) bool {
+	return orig.GetString("outbound") != record.GetString("outbound") ||
+		orig.GetString("inbound") != record.GetString("inbound")
+}
+
+// findRuleSetHosts returns every host referencing a firewall rule set.
+func (sm *Manager) findRuleSetHosts(ruleSetID string) ([]*core.Record, error) {
+	return sm.app.FindRecordsByFilter(sm.options.HostCollectionName,
+		"firewall_rulesets.id ?= {:id}", "", 0, 0, dbx.Params{"id": ruleSetID})
+}
+
+// regeneratePolicyNetworkConfigs regenerates the host configs of a policy's network.
+func (sm *Manager) regeneratePolicyNetworkConfigs(networkID string) {
+	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, networkID)
+	if err != nil {
+		sm.logger.Warning("Failed to find network %s for firewall policy: %v", networkID, err)
+		return
+	}
+
+	sm.regenerateNetworkConfigs(network)
+}
+
+// regenerateNetworkConfigs regenerates and saves the config of every host in a network
+// (see regenerateHostConfigs).
+func (sm *Manager) regenerateNetworkConfigs(network *core.Record) error {
+	hosts, err := sm.findNetworkHosts(network)
+	if err != nil {
+		sm.logger.Warning("Failed to find hosts in network %s: %v", network.Id, err)
+		return err
+	}
+
+	return sm.regenerateHostConfigs(hosts, "network "+network.GetString("name"))
+}
+
+// findNetworkHosts returns every host in a network.
+func (sm *Manager) findNetworkHosts(network *core.Record) ([]*core.Record, error) {
+	hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+		dbx.HashExp{"network_id": network.Id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find hosts in network: %w", err)
+	}
+	return hosts, nil
+}
+
+// regenerateHostConfigs regenerates and saves the config of each given host.
+// While serving, hosts are handed to the regeneration queue so the triggering
+// request doesn't wait for them. Otherwise they are regenerated synchronously
+// (see saveHostConfigs).
+//
+// FAILURE REPORTING:
+// Hooks can't fail a save that already succeeded, so they only log the returned
+// error. Hosts a fan-out couldn't update keep their previous configs, which
+// Doctor reports as stale (or as rejected, for the host that failed).
+//
+// RETURNS:
+// - nil if the hosts were queued or regenerated
+// - error if the synchronous regeneration failed (no host was changed)
+func (sm *Manager) regenerateHostConfigs(hosts []*core.Record, scope string) error {
+	if sm.queue.enqueue(hosts) {
+		sm.logger.Config("Queued config regeneration for %d hosts in %s", len(hosts), scope)
+		return nil
+	}
+
+	return sm.saveHostConfigs(hosts, scope)
+}
+
+// saveHostConfigs regenerates and saves the config of each given host right away.
+//
+// ALL OR NOTHING:
+// Every host is saved in a single transaction, like a queue batch. If any host's
+// config can't be generated, the failure is logged and returned and all hosts keep
+// their previous configs, so a network never ends up with a mix of old and new configs.
+// Stored credentials aren't re-verified (see refreshHostConfig).
+func (sm *Manager) saveHostConfigs(hosts []*core.Record, scope string) error {
+	err := sm.app.RunInTransaction(func(txApp core.App) error {
+		tx := sm.withApp(txApp)
+		for _, host := range hosts {
+			if err := tx.refreshHostConfig(host); err != nil {
+				return fmt.Errorf("host %s: %w", host.GetString("hostname"), err)
+			}
+			if err := txApp.Save(host); err != nil {
+				return fmt.Errorf("failed to save host %s: %w", host.GetString("hostname"), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sm.logger.Warning("Failed to regenerate configs in %s, no host was changed: %v", scope, err)
+		return fmt.Errorf("failed to regenerate configs in %s, no host was changed: %w", scope, err)
+	}
+
+	sm.logger.Success("Regenerated configs for %d hosts in %s", len(hosts), scope)
+	return nil
+}
+
 // setupHostHooks registers hooks for host lifecycle, validation, and certificate/config generation.
 //
 // HOST EVENT HANDLING:
-// - Creation: Generate certificate and config automatically after record is saved
-// - Validation: Validate IP, lighthouse requirements before creation/update
-// - Updates: Regenerate config when meaningful fields change (NOT during initial creation)
+// - Validation: Validate IP, lighthouse and relay requirements before creation/update
+// - Creation: Generate certificate and config as part of the insert
+// - Updates: Re-sign certificate or regenerate config as part of the update when meaningful fields change
+// - Lighthouse/relay changes: Regenerate configs of every host in the network
+//
+// ATOMIC WRITES:
+// - Generation runs in the execute hooks, inside the save's transaction and before the
+//   write, so the certificate and config are stored in the same write as the change
+// - A generation failure fails the save: nothing is written and the caller gets the error
 //
 // RECURSION PREVENTION:
-// - Skip update processing if triggered by our own save during creation
+// - Generation modifies the record being saved instead of saving it again
 // - Only regenerate when groups, lighthouse status, or firewall rules change
 func (sm *Manager) setupHostHooks() {
 	// Host validation - validate IP and lighthouse requirements
@@ -226,9 +660,19 @@ func (sm *Manager) setupHostHooks() {
 			return fmt.Errorf("IP validation failed: %w", err)
 		}
 
-		// Validate lighthouse requirements
-		if e.Record.GetBool("is_lighthouse") && e.Record.GetString("public_host_port") == "" {
-			return fmt.Errorf("lighthouse hosts must specify public_host_port")
+		// Validate public endpoints and lighthouse/relay requirements
+		if err := sm.validatePublicEndpoints(e.Record); err != nil {
+			return err
+		}
+
+		// Validate lighthouse DNS settings
+		if err := validateLighthouseDNS(e.Record); err != nil {
+			return err
+		}
+
+		// Validate listen address and advertised addresses
+		if err := validateListenSettings(e.Record); err != nil {
+			return err
 		}
 
 		// Validate groups is valid JSON array
@@ -240,6 +684,25 @@ func (sm *Manager) setupHostHooks() {
 			}
 		}
 
+		// Validate firewall rules (broken rules would crash Nebula on the host)
+		if err := sm.validateFirewallRules(e.Record); err != nil {
+			return err
+		}
+
+		// Validate firewall actions and conntrack timeouts
+		if err := sm.validateFirewallSettings(e.Record); err != nil {
+			return err
+		}
+
+		// Validate IP and groups are allowed by the network's CA
+		if err := sm.validateHostCAConstraints(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateConfigOverrides(e.Record); err != nil {
+			return err
+		}
+
 		return e.Next()
 	})
 
@@ -258,9 +721,19 @@ func (sm *Manager) setupHostHooks() {
 			return fmt.Errorf("IP validation failed: %w", err)
 		}
 
-		// Validate lighthouse requirements
-		if e.Record.GetBool("is_lighthouse") && e.Record.GetString("public_host_port") == "" {
-			return fmt.Errorf("lighthouse hosts must specify public_host_port")
+		// Validate public endpoints and lighthouse/relay requirements
+		if err := sm.validatePublicEndpoints(e.Record); err != nil {
+			return err
+		}
+
+		// Validate lighthouse DNS settings
+		if err := validateLighthouseDNS(e.Record); err != nil {
+			return err
+		}
+
+		// Validate listen address and advertised addresses
+		if err := validateListenSettings(e.Record); err != nil {
+			return err
 		}
 
 		// Validate groups is valid JSON array
@@ -272,11 +745,36 @@ func (sm *Manager) setupHostHooks() {
 			}
 		}
 
+		// Validate firewall rules (broken rules would crash Nebula on the host)
+		if err := sm.validateFirewallRules(e.Record); err != nil {
+			return err
+		}
+
+		// Validate firewall actions and conntrack timeouts
+		if err := sm.validateFirewallSettings(e.Record); err != nil {
+			return err
+		}
+
+		// Validate IP and groups are allowed by the network's CA
+		if err := sm.validateHostCAConstraints(e.Record); err != nil {
+			return err
+		}
+
+		if err := sm.validateConfigOverrides(e.Record); err != nil {
+			return err
+		}
+
+		// Preflight the config so changes Nebula would reject fail the save
+		if err := sm.preflightHostConfig(e.Record); err != nil {
+			return err
+		}
+
 		return e.Next()
 	})
 
-	// Host creation - generate certificate and config
-	sm.app.OnRecordAfterCreateSuccess().BindFunc(func(e *core.RecordEvent) error {
+	// Host creation - generate certificate and config before the insert,
+	// so a host is never stored without them
+	sm.app.OnRecordCreateExecute().BindFunc(func(e *core.RecordEvent) error {
 		if e.Record.Collection().Name != sm.options.HostCollectionName {
 			return e.Next()
 		}
@@ -289,42 +787,90 @@ func (sm *Manager) setupHostHooks() {
 		sm.logger.Cert("Generating certificate and config for host %s...", e.Record.GetString("hostname"))
 
 		// Generate host certificate and config
-		if err := sm.generateHostCertAndConfig(e.Record); err != nil {
+		if err := sm.withApp(e.App).generateHostCertAndConfig(e.Record); err != nil {
 			sm.logger.Error("Failed to generate host certificate/config: %v", err)
 			return fmt.Errorf("failed to generate host certificate/config: %w", err)
 		}
 
-		if err := sm.app.Save(e.Record); err != nil {
-			return fmt.Errorf("failed to save host record: %w", err)
+		if err := validateGenerated(e); err != nil {
+			return err
+		}
+
+		if err := e.Next(); err != nil {
+			return err
 		}
 
 		sm.logger.Success("Generated certificate and config for host %s", e.Record.GetString("hostname"))
 
-		return e.Next()
+		return nil
 	})
 
-	// Host updates - regenerate certificate OR config depending on what changed
-	// Certificate regeneration: groups, validity_years (embedded in cert)
-	// Config regeneration: lighthouse, firewall rules (only in config)
-	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
+	// Lighthouse/relay changes - every host in the network lists lighthouses, relays
+	// and DNS resolvers in its config
+	sm.app.OnRecordAfterCreateSuccess().BindFunc(func(e *core.RecordEvent) error {
 		if e.Record.Collection().Name != sm.options.HostCollectionName {
 			return e.Next()
 		}
 
-		orig := e.Record.Original()
-
-		// CRITICAL: Skip if certificate was JUST generated (prevents recursion during creation)
-		// Only skip if: certificate went from empty -> populated (initial generation)
-		if orig != nil && 
-		   orig.GetString("certificate") == "" && 
-		   e.Record.GetString("certificate") != "" {
-			sm.logger.Info("Skipping regeneration for %s (initial certificate generation)", e.Record.GetString("hostname"))
+		if !sharesNetworkRole(e.Record) || !sm.shouldHandleEvent(sm.options.HostCollectionName, types.EventTypeHostCreate) {
 			return e.Next()
 		}
 
-		// Check if event should be handled by user-defined filter
-		if !sm.shouldHandleEvent(sm.options.HostCollectionName, types.EventTypeHostUpdate) {
-			return e.Next()
+		sm.logger.Info("Lighthouse/relay %s added, regenerating network configs...", e.Record.GetString("hostname"))
+		sm.regenerateHostNetworkConfigs(e.Record)
+
+		return e.Next()
+	})
+
+	sm.app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.HostCollectionName {
+			return e.Next()
+		}
+
+		orig := e.Record.Original()
+		if orig == nil || !networkRoleChanged(orig, e.Record) {
+			return e.Next()
+		}
+
+		if !sm.shouldHandleEvent(sm.options.HostCollectionName, types.EventTypeHostUpdate) {
+			return e.Next()
+		}
+
+		sm.logger.Info("Lighthouse/relay settings changed for host %s, regenerating network configs...", e.Record.GetString("hostname"))
+		sm.regenerateHostNetworkConfigs(e.Record)
+
+		return e.Next()
+	})
+
+	sm.app.OnRecordAfterDeleteSuccess().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.HostCollectionName {
+			return e.Next()
+		}
+
+		if !sharesNetworkRole(e.Record) || !sm.shouldHandleEvent(sm.options.HostCollectionName, types.EventTypeHostDelete) {
+			return e.Next()
+		}
+
+		sm.logger.Info("Lighthouse/relay %s removed, regenerating network configs...", e.Record.GetString("hostname"))
+		sm.regenerateHostNetworkConfigs(e.Record)
+
+		return e.Next()
+	})
+
+	// Host updates - re-sign certificate OR regenerate config depending on what changed
+	// Certificate re-signing: groups, validity_years (embedded in cert, key is kept)
+	// Config regeneration: lighthouse, firewall rules (only in config)
+	// Runs before the update is written, so the regenerated fields are stored with it
+	sm.app.OnRecordUpdateExecute().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name != sm.options.HostCollectionName {
+			return e.Next()
+		}
+
+		orig := e.Record.Original()
+
+		// Check if event should be handled by user-defined filter
+		if !sm.shouldHandleEvent(sm.options.HostCollectionName, types.EventTypeHostUpdate) {
+			return e.Next()
 		}
 
 		needsCertRegeneration := false
@@ -347,10 +893,34 @@ func (sm *Manager) setupHostHooks() {
 					sm.logger.Info("Lighthouse status changed for host %s, regenerating config", e.Record.GetString("hostname"))
 					needsConfigRegeneration = true
 				}
+				if orig.GetBool("is_relay") != e.Record.GetBool("is_relay") {
+					sm.logger.Info("Relay status changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetBool("active") != e.Record.GetBool("active") {
+					sm.logger.Info("Active status changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
 				if orig.GetString("public_host_port") != e.Record.GetString("public_host_port") {
 					sm.logger.Info("Public host/port changed for host %s, regenerating config", e.Record.GetString("hostname"))
 					needsConfigRegeneration = true
 				}
+				if orig.GetString("public_endpoints") != e.Record.GetString("public_endpoints") {
+					sm.logger.Info("Public endpoints changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetString("listen_host") != e.Record.GetString("listen_host") ||
+					orig.GetInt("listen_port") != e.Record.GetInt("listen_port") ||
+					orig.GetString("advertise_addrs") != e.Record.GetString("advertise_addrs") {
+					sm.logger.Info("Listen settings changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetBool("serve_dns") != e.Record.GetBool("serve_dns") ||
+					orig.GetString("dns_host") != e.Record.GetString("dns_host") ||
+					orig.GetInt("dns_port") != e.Record.GetInt("dns_port") {
+					sm.logger.Info("DNS settings changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
 				if orig.GetString("firewall_outbound") != e.Record.GetString("firewall_outbound") {
 					sm.logger.Info("Firewall outbound rules changed for host %s, regenerating config", e.Record.GetString("hostname"))
 					needsConfigRegeneration = true
@@ -359,6 +929,22 @@ func (sm *Manager) setupHostHooks() {
 					sm.logger.Info("Firewall inbound rules changed for host %s, regenerating config", e.Record.GetString("hostname"))
 					needsConfigRegeneration = true
 				}
+				if !slices.Equal(orig.GetStringSlice("firewall_rulesets"), e.Record.GetStringSlice("firewall_rulesets")) {
+					sm.logger.Info("Firewall rule sets changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetString("firewall_settings") != e.Record.GetString("firewall_settings") {
+					sm.logger.Info("Firewall settings changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetString("config_overrides") != e.Record.GetString("config_overrides") {
+					sm.logger.Info("Config overrides changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
+				if orig.GetString("template_id") != e.Record.GetString("template_id") {
+					sm.logger.Info("Config template changed for host %s, regenerating config", e.Record.GetString("hostname"))
+					needsConfigRegeneration = true
+				}
 			}
 		} else {
 			// If we don't have original data, regenerate cert to be safe
@@ -371,43 +957,97 @@ func (sm *Manager) setupHostHooks() {
 			return e.Next()
 		}
 
-		// Regenerate certificate (which also regenerates config)
+		// Regenerate the record being saved. A failure fails the update, so the host
+		// never stores changed groups or settings with a certificate/config that lacks them.
+		tx := sm.withApp(e.App)
+
+		// Re-sign certificate with the existing key (which also regenerates config)
 		if needsCertRegeneration {
 			sm.logger.Cert("Regenerating certificate and config for host %s...", e.Record.GetString("hostname"))
-			
-			if err := sm.generateHostCertAndConfig(e.Record); err != nil {
+
+			if err := tx.resignHostCertAndConfig(e.Record); err != nil {
 				sm.logger.Error("Failed to regenerate certificate for host %s: %v", e.Record.Id, err)
-				return e.Next()
+				return fmt.Errorf("failed to regenerate host certificate: %w", err)
 			}
-			
-			if err := sm.app.Save(e.Record); err != nil {
-				sm.logger.Warning("Failed to save host %s: %v", e.Record.Id, err)
+
+			if err := validateGenerated(e); err != nil {
+				return err
 			}
-			
+
+			if err := e.Next(); err != nil {
+				return err
+			}
+
 			sm.logger.Success("Regenerated certificate and config for host %s", e.Record.GetString("hostname"))
-			return e.Next()
+			return nil
 		}
 
 		// Only regenerate config (cheaper operation)
-		if needsConfigRegeneration {
-			sm.logger.Config("Regenerating config for host %s...", e.Record.GetString("hostname"))
-			
-			if err := sm.generateHostConfig(e.Record); err != nil {
-				sm.logger.Warning("Failed to regenerate config for host %s: %v", e.Record.Id, err)
-				return e.Next()
-			}
-			
-			if err := sm.app.Save(e.Record); err != nil {
-				sm.logger.Warning("Failed to save host %s: %v", e.Record.Id, err)
-			}
-			
-			sm.logger.Success("Regenerated config for host %s", e.Record.GetString("hostname"))
+		sm.logger.Config("Regenerating config for host %s...", e.Record.GetString("hostname"))
+
+		if err := tx.generateHostConfig(e.Record); err != nil {
+			sm.logger.Warning("Failed to regenerate config for host %s: %v", e.Record.Id, err)
+			return fmt.Errorf("failed to regenerate host config: %w", err)
 		}
 
-		return e.Next()
+		if err := validateGenerated(e); err != nil {
+			return err
+		}
+
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		sm.logger.Success("Regenerated config for host %s", e.Record.GetString("hostname"))
+
+		return nil
 	})
 }
 
+// validateGenerated validates a record again after an execute hook generated fields into it.
+// The save's own validation runs before the execute hooks, so it never saw them.
+func validateGenerated(e *core.RecordEvent) error {
+	return e.App.ValidateWithContext(e.Context, e.Record)
+}
+
+// sharesNetworkRole reports whether a host appears in other hosts' configs.
+// Active lighthouses and relays are listed in every host config of their network.
+func sharesNetworkRole(record *core.Record) bool {
+	return record.GetBool("active") && (record.GetBool("is_lighthouse") || record.GetBool("is_relay"))
+}
+
+// networkRoleFields are the host fields rendered into other hosts' configs.
+var networkRoleFields = []string{
+	"is_lighthouse", "is_relay", "overlay_ip", "public_host_port", "public_endpoints", "serve_dns", "dns_host", "dns_port",
+}
+
+// networkRoleChanged reports whether a host update changes what other hosts in its network see.
+func networkRoleChanged(orig, record *core.Record) bool {
+	if sharesNetworkRole(orig) != sharesNetworkRole(record) {
+		return true
+	}
+	if !sharesNetworkRole(record) {
+		return false
+	}
+	for _, field := range networkRoleFields {
+		if orig.GetString(field) != record.GetString(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// regenerateHostNetworkConfigs regenerates the configs of every host in a host's network.
+func (sm *Manager) regenerateHostNetworkConfigs(host *core.Record) {
+	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, host.GetString("network_id"))
+	if err != nil {
+		sm.logger.Warning("Failed to find network for host %s: %v", host.Id, err)
+		return
+	}
+
+	sm.regenerateNetworkConfigs(network)
+}
+
 // generateCA generates CA certificate and updates the record.
 func (sm *Manager) generateCA(record *core.Record) error {
 	name := record.GetString("name")
@@ -416,7 +1056,21 @@ func (sm *Manager) generateCA(record *core.Record) error {
 		validityYears = sm.options.DefaultCAValidityYears
 	}
 
-	result, err := sm.certManager.GenerateCA(name, validityYears)
+	allowedNetworks, err := jsonStringArray(record, "allowed_networks")
+	if err != nil {
+		return err
+	}
+	allowedGroups, err := jsonStringArray(record, "allowed_groups")
+	if err != nil {
+		return err
+	}
+
+	result, err := sm.certManager.GenerateCA(cert.CAParams{
+		Name:            name,
+		ValidityYears:   validityYears,
+		AllowedNetworks: allowedNetworks,
+		AllowedGroups:   allowedGroups,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to generate CA: %w", err)
 	}
@@ -425,6 +1079,8 @@ func (sm *Manager) generateCA(record *core.Record) error {
 	record.Set("private_key", result.PrivateKeyPEM)
 	record.Set("expires_at", result.ExpiresAt)
 	record.Set("curve", "CURVE25519")
+	record.Set("fingerprint", result.Fingerprint)
+	record.Set("issuer", "") // Self-signed, no issuer
 	if validityYears > 0 {
 		record.Set("validity_years", validityYears)
 	}
@@ -432,8 +1088,31 @@ func (sm *Manager) generateCA(record *core.Record) error {
 	return nil
 }
 
-// generateHostCertAndConfig generates host certificate and config, updating the record.
+// generateHostCertAndConfig generates a new host key pair, certificate and config, updating the record.
 func (sm *Manager) generateHostCertAndConfig(record *core.Record) error {
+	return sm.issueHostCertAndConfig(record, nil)
+}
+
+// resignHostCertAndConfig issues a new certificate for the host's existing key and regenerates config.
+// Falls back to a new key pair if there is no usable existing certificate/key pair.
+func (sm *Manager) resignHostCertAndConfig(record *core.Record) error {
+	certPEM := record.GetString("certificate")
+	if certPEM == "" {
+		return sm.generateHostCertAndConfig(record)
+	}
+
+	publicKey, err := sm.certManager.HostPublicKey(certPEM, record.GetString("private_key"))
+	if err != nil {
+		sm.logger.Warning("Cannot reuse key for host %s, generating new key pair: %v", record.GetString("hostname"), err)
+		return sm.generateHostCertAndConfig(record)
+	}
+
+	return sm.issueHostCertAndConfig(record, publicKey)
+}
+
+// issueHostCertAndConfig signs a host certificate and generates config, updating the record.
+// A nil publicKey generates a new key pair, otherwise the given key is re-signed.
+func (sm *Manager) issueHostCertAndConfig(record *core.Record, publicKey []byte) error {
 	// Get network and CA
 	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, record.GetString("network_id"))
 	if err != nil {
@@ -469,16 +1148,22 @@ func (sm *Manager) generateHostCertAndConfig(record *core.Record) error {
 		CACertPEM:       ca.GetString("certificate"),
 		CAPrivateKeyPEM: ca.GetString("private_key"),
 		CAExpiresAt:     ca.GetDateTime("expires_at").Time(),
+		PublicKey:       publicKey,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to generate host certificate: %w", err)
 	}
 
 	// Store certificate and CA cert (denormalized)
+	// Private key only changes when a new key pair was generated
 	record.Set("certificate", certResult.CertificatePEM)
-	record.Set("private_key", certResult.PrivateKeyPEM)
+	if certResult.PrivateKeyPEM != "" {
+		record.Set("private_key", certResult.PrivateKeyPEM)
+	}
 	record.Set("ca_certificate", ca.GetString("certificate"))
 	record.Set("expires_at", certResult.ExpiresAt)
+	record.Set("fingerprint", certResult.Fingerprint)
+	record.Set("issuer", certResult.Issuer)
 	if validityYears > 0 {
 		record.Set("validity_years", validityYears)
 	}
@@ -487,31 +1172,171 @@ func (sm *Manager) generateHostCertAndConfig(record *core.Record) error {
 	return sm.generateHostConfig(record)
 }
 
-// generateHostConfig generates Nebula config for a host and updates the record.
+// generateHostConfig generates Nebula config for a host being saved and updates the record.
+// The host's certificate, key and CA chain are verified too.
 func (sm *Manager) generateHostConfig(record *core.Record) error {
+	return sm.buildHostConfig(record, true)
+}
+
+// refreshHostConfig regenerates the config of a host affected by a change to another
+// record (network, template, policy, another host) and updates the record.
+// Only the generated config is validated. The host's stored credentials aren't
+// re-verified, so one host with an expired or mismatched certificate can't hold back
+// a network-wide regeneration - the certificate audit and doctor report such hosts.
+func (sm *Manager) refreshHostConfig(record *core.Record) error {
+	return sm.buildHostConfig(record, false)
+}
+
+// buildHostConfig generates, validates and lints the config of a host and updates the record.
+func (sm *Manager) buildHostConfig(record *core.Record, verifyChain bool) error {
+	params, err := sm.hostConfigParams(record)
+	if err != nil {
+		return err
+	}
+
+	// Generate config (now uses host-level firewall rules)
+	configYAML, err := sm.configGen.GenerateHostConfig(params)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	// Never store a config the Nebula daemon would reject
+	if err := sm.verifyHostConfig(configYAML, verifyChain); err != nil {
+		return err
+	}
+
+	// Lint the effective firewall - findings are reported, never fatal
+	findings, err := sm.lintHostFirewall(params)
+	if err != nil {
+		sm.logger.Warning("Failed to lint firewall of host %s: %v", record.GetString("hostname"), err)
+		findings = []firewall.Finding{}
+	}
+	if len(findings) > 0 {
+		sm.logger.Warning("Firewall of host %s has %d findings", record.GetString("hostname"), len(findings))
+	}
+
+	record.Set("config_yaml", configYAML)
+	record.Set("firewall_findings", findings)
+	return nil
+}
+
+// verifyHostConfig validates a generated config (see config.Generator.ValidateConfig)
+// and, with verifyChain, verifies its certificate, key and CA chain.
+func (sm *Manager) verifyHostConfig(configYAML string, verifyChain bool) error {
+	pki, err := sm.configGen.ValidateConfig(configYAML)
+	if err != nil {
+		return fmt.Errorf("generated config rejected: %w", err)
+	}
+	if !verifyChain {
+		return nil
+	}
+
+	if err := sm.certManager.VerifyHostChain(pki.CA, pki.Cert, pki.Key); err != nil {
+		return fmt.Errorf("generated config rejected: %w", err)
+	}
+
+	return nil
+}
+
+// preflightHostConfig generates the config of a host from a pending update and
+// validates it like verifyHostConfig, so changes that would produce a config
+// Nebula rejects (e.g. a broken template or override) fail the save.
+// Hosts without credentials are checked when their certificate is issued.
+func (sm *Manager) preflightHostConfig(record *core.Record) error {
+	if record.GetString("certificate") == "" {
+		return nil
+	}
+
+	params, err := sm.hostConfigParams(record)
+	if err != nil {
+		return err
+	}
+
+	configYAML, err := sm.configGen.GenerateHostConfig(params)
+	if err != nil {
+		return fmt.Errorf("failed to generate config: %w", err)
+	}
+
+	if _, err := sm.configGen.ValidateConfig(configYAML); err != nil {
+		return fmt.Errorf("generated config rejected: %w", err)
+	}
+	return nil
+}
+
+// hostConfigParams gathers everything the config generator needs for a host.
+func (sm *Manager) hostConfigParams(record *core.Record) (config.HostConfigParams, error) {
 	// Get network
 	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, record.GetString("network_id"))
 	if err != nil {
-		return fmt.Errorf("network not found: %w", err)
+		return config.HostConfigParams{}, fmt.Errorf("network not found: %w", err)
 	}
 
 	// Query lighthouses in this network
 	lighthouses, err := sm.getLighthouses(network.Id)
 	if err != nil {
-		return fmt.Errorf("failed to get lighthouses: %w", err)
+		return config.HostConfigParams{}, fmt.Errorf("failed to get lighthouses: %w", err)
 	}
 
-	// Convert records to models
-	hostModel := sm.recordToHostModel(record)
+	// Query relays in this network (a relay doesn't relay through itself)
+	relays, err := sm.getRelays(network.Id, record.Id)
+	if err != nil {
+		return config.HostConfigParams{}, fmt.Errorf("failed to get relays: %w", err)
+	}
 
-	// Generate config (now uses host-level firewall rules)
-	configYAML, err := sm.configGen.GenerateHostConfig(hostModel, lighthouses)
+	// Query firewall policies of this network
+	policies, err := sm.getPolicies(network.Id)
 	if err != nil {
-		return fmt.Errorf("failed to generate config: %w", err)
+		return config.HostConfigParams{}, fmt.Errorf("failed to get firewall policies: %w", err)
 	}
 
-	record.Set("config_yaml", configYAML)
-	return nil
+	// Resolve firewall rule sets referenced by the host
+	ruleSets, err := sm.getRuleSets(record)
+	if err != nil {
+		return config.HostConfigParams{}, fmt.Errorf("failed to get firewall rule sets: %w", err)
+	}
+
+	// Resolve config template (host template wins over network template)
+	template, err := sm.resolveTemplate(record, network)
+	if err != nil {
+		return config.HostConfigParams{}, err
+	}
+
+	// Resolve default firewall rules (network settings win over Options)
+	networkModel := sm.recordToNetworkModel(network)
+	defaults, err := sm.firewallDefaults(networkModel)
+	if err != nil {
+		return config.HostConfigParams{}, err
+	}
+
+	return config.HostConfigParams{
+		Host:        sm.recordToHostModel(record),
+		Network:     networkModel,
+		Lighthouses: lighthouses,
+		Relays:      relays,
+		Policies:    policies,
+		RuleSets:    ruleSets,
+		Template:    template,
+		Defaults:    defaults,
+	}, nil
+}
+
+// resolveTemplate returns the config template content assigned to a host.
+// Host template wins over network template, empty means the built-in layout.
+func (sm *Manager) resolveTemplate(host, network *core.Record) (string, error) {
+	templateID := host.GetString("template_id")
+	if templateID == "" {
+		templateID = network.GetString("template_id")
+	}
+	if templateID == "" {
+		return "", nil
+	}
+
+	template, err := sm.app.FindRecordById(sm.options.TemplateCollectionName, templateID)
+	if err != nil {
+		return "", fmt.Errorf("config template not found: %w", err)
+	}
+
+	return template.GetString("content"), nil
 }
 
 // getLighthouses queries all lighthouse hosts in a network.
@@ -524,15 +1349,309 @@ func (sm *Manager) getLighthouses(networkID string) ([]types.LighthouseInfo, err
 
 	lighthouses := make([]types.LighthouseInfo, len(records))
 	for i, record := range records {
+		endpoints, err := sm.recordToHostModel(record).GetPublicEndpoints()
+		if err != nil {
+			return nil, fmt.Errorf("invalid public endpoints for lighthouse %s: %w", record.GetString("hostname"), err)
+		}
+
 		lighthouses[i] = types.LighthouseInfo{
-			OverlayIP:      record.GetString("overlay_ip"),
-			PublicHostPort: record.GetString("public_host_port"),
+			OverlayIP:       record.GetString("overlay_ip"),
+			PublicHostPort:  record.GetString("public_host_port"),
+			PublicEndpoints: endpoints,
+			ServeDNS:        record.GetBool("serve_dns"),
+			DNSHost:         record.GetString("dns_host"),
+			DNSPort:         record.GetInt("dns_port"),
 		}
 	}
 
 	return lighthouses, nil
 }
 
+// getRelays queries the overlay IPs of all relay hosts in a network, except the given host.
+func (sm *Manager) getRelays(networkID, excludeHostID string) ([]string, error) {
+	records, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+		dbx.HashExp{"network_id": networkID, "is_relay": true, "active": true})
+	if err != nil {
+		return nil, err
+	}
+
+	relays := make([]string, 0, len(records))
+	for _, record := range records {
+		if record.Id == excludeHostID {
+			continue
+		}
+		relays = append(relays, record.GetString("overlay_ip"))
+	}
+
+	return relays, nil
+}
+
+// getPolicies queries all active firewall policies of a network.
+func (sm *Manager) getPolicies(networkID string) ([]types.FirewallPolicyRecord, error) {
+	records, err := sm.app.FindAllRecords(sm.options.PolicyCollectionName,
+		dbx.HashExp{"network_id": networkID, "active": true})
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]types.FirewallPolicyRecord, len(records))
+	for i, record := range records {
+		policies[i] = *sm.recordToPolicyModel(record)
+	}
+
+	return policies, nil
+}
+
+// getNetworkGroups returns every group carried by a host in a network (sorted, unique).
+func (sm *Manager) getNetworkGroups(networkID string) ([]string, error) {
+	hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+		dbx.HashExp{"network_id": networkID})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, host := range hosts {
+		hostGroups, err := sm.recordToHostModel(host).GetGroups()
+		if err != nil {
+			continue // Invalid groups are rejected on save, skip legacy data
+		}
+		groups = append(groups, hostGroups...)
+	}
+
+	slices.Sort(groups)
+	return slices.Compact(groups), nil
+}
+
+// getRuleSets resolves the firewall rule sets referenced by a host, in reference order.
+func (sm *Manager) getRuleSets(host *core.Record) ([]types.FirewallRuleSetRecord, error) {
+	ids := host.GetStringSlice("firewall_rulesets")
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	records, err := sm.app.FindRecordsByIds(sm.options.RuleSetCollectionName, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*core.Record, len(records))
+	for _, record := range records {
+		byID[record.Id] = record
+	}
+
+	ruleSets := make([]types.FirewallRuleSetRecord, 0, len(ids))
+	for _, id := range ids {
+		record, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("firewall rule set %s not found", id)
+		}
+		ruleSets = append(ruleSets, *sm.recordToRuleSetModel(record))
+	}
+
+	return ruleSets, nil
+}
+
+// validateCAConstraints checks the allowed_networks and allowed_groups fields of a CA record.
+func (sm *Manager) validateCAConstraints(record *core.Record) error {
+	allowedNetworks, err := jsonStringArray(record, "allowed_networks")
+	if err != nil {
+		return err
+	}
+	for _, cidr := range allowedNetworks {
+		if err := sm.ipamManager.ValidateNetworkCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid allowed network: %w", err)
+		}
+	}
+
+	allowedGroups, err := jsonStringArray(record, "allowed_groups")
+	if err != nil {
+		return err
+	}
+	for _, group := range allowedGroups {
+		if group == "" {
+			return fmt.Errorf("allowed_groups cannot contain empty group names")
+		}
+	}
+
+	return nil
+}
+
+// validateHostCAConstraints checks a host's overlay IP and groups against its network's CA constraints.
+func (sm *Manager) validateHostCAConstraints(record *core.Record) error {
+	network, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, record.GetString("network_id"))
+	if err != nil {
+		return fmt.Errorf("network not found: %w", err)
+	}
+
+	ca, err := sm.app.FindRecordById(sm.options.CACollectionName, network.GetString("ca_id"))
+	if err != nil {
+		return fmt.Errorf("CA not found: %w", err)
+	}
+
+	// CA certificate not generated yet - nothing to check against
+	if ca.GetString("certificate") == "" {
+		return nil
+	}
+
+	groups, err := jsonStringArray(record, "groups")
+	if err != nil {
+		return err
+	}
+
+	if err := sm.certManager.CheckHostConstraints(ca.GetString("certificate"), record.GetString("overlay_ip"), groups); err != nil {
+		return fmt.Errorf("CA constraint violation: %w", err)
+	}
+
+	return nil
+}
+
+// validatePublicEndpoints checks the public endpoints of a host record.
+// Every endpoint must parse (see types.ParseEndpoint), and lighthouses and relays
+// must be reachable directly, so they need at least one endpoint.
+func (sm *Manager) validatePublicEndpoints(record *core.Record) error {
+	if raw := record.GetString("public_host_port"); raw != "" {
+		if _, err := types.ParseEndpoint(raw); err != nil {
+			return fmt.Errorf("public_host_port: %w", err)
+		}
+	}
+
+	additional, err := jsonStringArray(record, "public_endpoints")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(additional))
+	for i, raw := range additional {
+		endpoint, err := types.ParseEndpoint(raw)
+		if err != nil {
+			return fmt.Errorf("public_endpoints[%d]: %w", i, err)
+		}
+		if seen[endpoint.String()] {
+			return fmt.Errorf("public_endpoints[%d] duplicates endpoint %q", i, raw)
+		}
+		seen[endpoint.String()] = true
+	}
+
+	hasEndpoint := record.GetString("public_host_port") != "" || len(additional) > 0
+	if record.GetBool("is_lighthouse") && !hasEndpoint {
+		return types.ErrLighthouseNoPublicIP
+	}
+	if record.GetBool("is_relay") && !hasEndpoint {
+		return types.ErrRelayNoPublicIP
+	}
+
+	return nil
+}
+
+// validateListenSettings checks the listen_host and advertise_addrs fields of a host record.
+// listen_port range is enforced by the collection schema.
+func validateListenSettings(record *core.Record) error {
+	if listenHost := record.GetString("listen_host"); listenHost != "" && net.ParseIP(listenHost) == nil {
+		return fmt.Errorf("listen_host must be an IP address: %q", listenHost)
+	}
+
+	addrs, err := jsonStringArray(record, "advertise_addrs")
+	if err != nil {
+		return err
+	}
+	for i, addr := range addrs {
+		if _, err := types.ParseAdvertiseAddr(addr); err != nil {
+			return fmt.Errorf("advertise_addrs[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateLighthouseDNS checks the DNS server fields of a host record.
+// Nebula only serves DNS on lighthouses, so the toggle is rejected elsewhere.
+func validateLighthouseDNS(record *core.Record) error {
+	if record.GetBool("serve_dns") && !record.GetBool("is_lighthouse") {
+		return fmt.Errorf("serve_dns is only supported on lighthouse hosts")
+	}
+
+	if dnsHost := record.GetString("dns_host"); dnsHost != "" && net.ParseIP(dnsHost) == nil {
+		return fmt.Errorf("dns_host must be an IP address: %q", dnsHost)
+	}
+
+	return nil
+}
+
+// validateFirewallRules checks the firewall_outbound and firewall_inbound fields of a host record.
+func (sm *Manager) validateFirewallRules(record *core.Record) error {
+	outbound, inbound, err := sm.recordToHostModel(record).GetFirewallRules()
+	if err != nil {
+		return fmt.Errorf("%w: rules must be JSON arrays of objects: %v", firewall.ErrInvalidFirewall, err)
+	}
+
+	if err := firewall.ValidateRules("outbound", outbound); err != nil {
+		return err
+	}
+	return firewall.ValidateRules("inbound", inbound)
+}
+
+// validateFirewallSettings checks the firewall_settings field of a network or host record.
+func (sm *Manager) validateFirewallSettings(record *core.Record) error {
+	settings, err := types.ParseFirewallSettings(record.GetString("firewall_settings"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", firewall.ErrInvalidFirewall, err)
+	}
+	return firewall.ValidateSettings(settings)
+}
+
+// validateConfigOverrides checks the config_overrides field of a network or host record.
+func (sm *Manager) validateConfigOverrides(record *core.Record) error {
+	overrides, err := types.ParseConfigOverrides(record.GetString("config_overrides"))
+	if err != nil {
+		return err
+	}
+	return sm.configGen.ValidateOverrides(overrides)
+}
+
+// validateFirewallDefaults checks the default firewall mode and rules of a network record.
+func (sm *Manager) validateFirewallDefaults(record *core.Record) error {
+	defaults, err := sm.recordToNetworkModel(record).GetFirewallDefaults()
+	if err != nil {
+		return fmt.Errorf("%w: default firewall rules must be JSON arrays of rule objects: %v", firewall.ErrInvalidFirewall, err)
+	}
+	if defaults == nil {
+		return nil
+	}
+	return firewall.ValidateDefaults(*defaults)
+}
+
+// firewallDefaults resolves the default firewall rules of a network:
+// the network's own settings when a mode is set, otherwise Options.
+func (sm *Manager) firewallDefaults(network *types.NetworkRecord) (types.FirewallDefaults, error) {
+	defaults, err := network.GetFirewallDefaults()
+	if err != nil {
+		return types.FirewallDefaults{}, fmt.Errorf("invalid default firewall rules: %w", err)
+	}
+	if defaults != nil {
+		return *defaults, nil
+	}
+
+	return types.FirewallDefaults{
+		Mode:     sm.options.DefaultFirewallMode,
+		Outbound: sm.options.DefaultFirewallOutbound,
+		Inbound:  sm.options.DefaultFirewallInbound,
+	}, nil
+}
+
+// jsonStringArray parses a JSON string array field, treating empty and null as no values.
+func jsonStringArray(record *core.Record, field string) ([]string, error) {
+	raw := record.GetString(field)
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, fmt.Errorf("%s must be a valid JSON array of strings: %w", field, err)
+	}
+	return values, nil
+}
+
 // shouldHandleEvent determines if an event should be processed based on configured filters.
 func (sm *Manager) shouldHandleEvent(collectionName, eventType string) bool {
 	if sm.options.EventFilter != nil {
@@ -549,12 +1668,74 @@ func (sm *Manager) recordToHostModel(record *core.Record) *types.HostRecord {
 		OverlayIP:        record.GetString("overlay_ip"),
 		Groups:           record.GetString("groups"),
 		IsLighthouse:     record.GetBool("is_lighthouse"),
+		IsRelay:          record.GetBool("is_relay"),
+		ServeDNS:         record.GetBool("serve_dns"),
+		DNSHost:          record.GetString("dns_host"),
+		DNSPort:          record.GetInt("dns_port"),
 		PublicHostPort:   record.GetString("public_host_port"),
+		PublicEndpoints:  record.GetString("public_endpoints"),
+		ListenHost:       record.GetString("listen_host"),
+		ListenPort:       record.GetInt("listen_port"),
+		AdvertiseAddrs:   record.GetString("advertise_addrs"),
 		Certificate:      record.GetString("certificate"),
 		PrivateKey:       record.GetString("private_key"),
 		CACertificate:    record.GetString("ca_certificate"),
+		Fingerprint:      record.GetString("fingerprint"),
+		Issuer:           record.GetString("issuer"),
 		ConfigYAML:       record.GetString("config_yaml"),
 		FirewallOutbound: record.GetString("firewall_outbound"),
 		FirewallInbound:  record.GetString("firewall_inbound"),
+		FirewallRuleSets: record.GetStringSlice("firewall_rulesets"),
+		FirewallFindings: record.GetString("firewall_findings"),
+		FirewallSettings: record.GetString("firewall_settings"),
+		ConfigOverrides:  record.GetString("config_overrides"),
+		TemplateID:       record.GetString("template_id"),
+		KeyGeneration:    record.GetInt("key_generation"),
+	}
+}
+
+// Helper: Convert PocketBase record to network model
+func (sm *Manager) recordToNetworkModel(record *core.Record) *types.NetworkRecord {
+	return &types.NetworkRecord{
+		ID:              record.Id,
+		Name:            record.GetString("name"),
+		CIDRRange:       record.GetString("cidr_range"),
+		Description:     record.GetString("description"),
+		CAID:            record.GetString("ca_id"),
+		TemplateID:      record.GetString("template_id"),
+		Blocklist:       record.GetString("blocklist"),
+		ConfigOverrides: record.GetString("config_overrides"),
+		Active:          record.GetBool("active"),
+
+		FirewallDefaultMode:     record.GetString("firewall_default_mode"),
+		FirewallDefaultOutbound: record.GetString("firewall_default_outbound"),
+		FirewallDefaultInbound:  record.GetString("firewall_default_inbound"),
+		FirewallSettings:        record.GetString("firewall_settings"),
+	}
+}
+
+// Helper: Convert PocketBase record to firewall policy model
+func (sm *Manager) recordToPolicyModel(record *core.Record) *types.FirewallPolicyRecord {
+	return &types.FirewallPolicyRecord{
+		ID:           record.Id,
+		Name:         record.GetString("name"),
+		Description:  record.GetString("description"),
+		NetworkID:    record.GetString("network_id"),
+		Direction:    record.GetString("direction"),
+		TargetGroups: record.GetString("target_groups"),
+		Rules:        record.GetString("rules"),
+		Active:       record.GetBool("active"),
+	}
+}
+
+// Helper: Convert PocketBase record to firewall rule set model
+func (sm *Manager) recordToRuleSetModel(record *core.Record) *types.FirewallRuleSetRecord {
+	return &types.FirewallRuleSetRecord{
+		ID:          record.Id,
+		Name:        record.GetString("name"),
+		Description: record.GetString("description"),
+		Outbound:    record.GetString("outbound"),
+		Inbound:     record.GetString("inbound"),
+		Version:     record.GetInt("version"),
 	}
 }
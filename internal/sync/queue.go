@@ -0,0 +1,379 @@
+package sync
+
+import (
+	"fmt"
+	gosync "sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	pbtypes "github.com/pocketbase/pocketbase/tools/types"
+)
+
+// QueueStats describes the regeneration queue for monitoring.
+type QueueStats struct {
+	Pending    int  `json:"pending"`    // Persisted jobs (debouncing, waiting for a worker or running)
+	Debouncing int  `json:"debouncing"` // Hosts waiting for their batch's debounce window to end
+	Active     int  `json:"active"`     // Hosts being regenerated right now
+	Workers    int  `json:"workers"`    // Worker pool size
+	Running    bool `json:"running"`    // Workers are started (only while serving)
+}
+
+// regenerationQueue regenerates host configs in the background.
+//
+// BATCHES:
+// The hosts of a fan-out form a batch that is regenerated and saved in one
+// transaction, so a fan-out changes every host or none, like synchronous regeneration.
+//
+// DEBOUNCING:
+// The first trigger starts a batch's debounce window. A later trigger sharing a
+// host with a debouncing batch joins it (overlapping batches merge and keep the
+// earliest window). When the window ends a worker regenerates the hosts from their
+// latest records, so one regeneration covers every change made in the window.
+//
+// BOUNDED WORKERS:
+// A fixed pool of workers regenerates batches, so a large number of fan-outs
+// can't start unbounded concurrent database writes.
+//
+// PERSISTENCE:
+// Every queued host has a job record in the queue collection. Jobs are deleted once
+// the host is regenerated and resumed on start, so pending regenerations survive restarts.
+// Resumed jobs form a single batch.
+//
+// LIFECYCLE:
+// Workers only run while serving. Without them (CLI commands, Go APIs) enqueue
+// reports false and callers regenerate synchronously.
+type regenerationQueue struct {
+	sm       *Manager      // Sync manager doing the regeneration
+	debounce time.Duration // Coalescing window per batch
+	workers  int           // Worker pool size
+
+	mu      gosync.Mutex                  // Guards the fields below
+	running bool                          // Workers are started
+	pending map[string]*regenerationBatch // Debouncing batch of each host
+	active  int                           // Hosts being regenerated
+	ready   chan []string                 // Batches of host IDs handed to workers
+	stop    chan struct{}                 // Closed on shutdown
+	wg      gosync.WaitGroup              // Running workers
+
+	persistMu gosync.Mutex // Serializes job record upserts (one record per host)
+}
+
+// regenerationBatch is a set of hosts regenerated in one transaction.
+type regenerationBatch struct {
+	hosts    []string    // Host IDs, without duplicates
+	deadline time.Time   // End of the debounce window
+	timer    *time.Timer // Hands the batch to a worker at the deadline
+	merged   bool        // Joined into another batch, the timer must not hand it over
+}
+
+// newRegenerationQueue creates a stopped queue using the manager's options.
+func newRegenerationQueue(sm *Manager) *regenerationQueue {
+	return &regenerationQueue{
+		sm:       sm,
+		debounce: sm.options.RegenerationDebounce,
+		workers:  max(sm.options.RegenerationWorkers, 1),
+		pending:  map[string]*regenerationBatch{},
+	}
+}
+
+// setupRegenerationQueue starts the queue workers with the server and stops them on shutdown.
+func (sm *Manager) setupRegenerationQueue() {
+	sm.app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		if err := sm.queue.start(); err != nil {
+			sm.logger.Warning("Failed to resume pending regenerations: %v", err)
+		}
+		return se.Next()
+	})
+
+	sm.app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		sm.queue.shutdown()
+		return e.Next()
+	})
+}
+
+// RegenerationQueueStats returns the queue depth and worker state for monitoring.
+//
+// RETURNS:
+// - QueueStats (Pending counts persisted jobs, so it includes jobs left from a previous run)
+// - error if the queue collection cannot be counted
+func (sm *Manager) RegenerationQueueStats() (QueueStats, error) {
+	pending, err := sm.app.CountRecords(sm.options.QueueCollectionName)
+	if err != nil {
+		return QueueStats{}, fmt.Errorf("failed to count regeneration jobs: %w", err)
+	}
+
+	q := sm.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return QueueStats{
+		Pending:    int(pending),
+		Debouncing: len(q.pending),
+		Active:     q.active,
+		Workers:    q.workers,
+		Running:    q.running,
+	}, nil
+}
+
+// start launches the workers and resumes persisted jobs.
+// Jobs whose host no longer exists are deleted, so the queue drains.
+func (q *regenerationQueue) start() error {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return nil
+	}
+	q.running = true
+	q.ready = make(chan []string)
+	q.stop = make(chan struct{})
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(q.ready, q.stop)
+	}
+	q.mu.Unlock()
+
+	jobs, err := q.sm.app.FindAllRecords(q.sm.options.QueueCollectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find regeneration jobs: %w", err)
+	}
+	resumed := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		hostID := job.GetString("host")
+		if _, err := q.sm.app.FindRecordById(q.sm.options.HostCollectionName, hostID); err != nil {
+			q.sm.logger.Warning("Dropping regeneration job %s: host %s not found in %s", job.Id, hostID, q.sm.options.HostCollectionName)
+			if err := q.sm.app.Delete(job); err != nil {
+				q.sm.logger.Warning("Failed to delete regeneration job %s: %v", job.Id, err)
+			}
+			continue
+		}
+		resumed = append(resumed, hostID)
+	}
+	if len(resumed) > 0 {
+		q.schedule(resumed)
+		q.sm.logger.Info("Resuming %d pending config regenerations", len(resumed))
+	}
+
+	return nil
+}
+
+// shutdown stops the workers after their current host.
+// Debouncing and waiting hosts keep their job records and resume on the next start.
+func (q *regenerationQueue) shutdown() {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return
+	}
+	q.running = false
+	for hostID, batch := range q.pending {
+		batch.timer.Stop()
+		delete(q.pending, hostID)
+	}
+	close(q.stop)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// enqueue persists the hosts' jobs and schedules them as one batch.
+//
+// RETURNS:
+// - true if the hosts were queued
+// - false if the workers aren't running (the caller should regenerate synchronously)
+func (q *regenerationQueue) enqueue(hosts []*core.Record) bool {
+	q.mu.Lock()
+	running := q.running
+	q.mu.Unlock()
+	if !running {
+		return false
+	}
+
+	// Jobs of a fan-out are persisted together, so a restart resumes all of them or none.
+	// Hosts without a job record are still regenerated, they just don't survive a restart.
+	if err := q.persist(hosts); err != nil {
+		q.sm.logger.Warning("Failed to persist regeneration jobs for %d hosts: %v", len(hosts), err)
+	}
+	hostIDs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		hostIDs = append(hostIDs, host.Id)
+	}
+	q.schedule(hostIDs)
+	return true
+}
+
+// persist creates or refreshes the job records of hosts in a single transaction.
+// Incrementing "revision" tells a worker already busy with a host to keep the job.
+func (q *regenerationQueue) persist(hosts []*core.Record) error {
+	q.persistMu.Lock()
+	defer q.persistMu.Unlock()
+
+	return q.sm.app.RunInTransaction(func(txApp core.App) error {
+		collection, err := txApp.FindCollectionByNameOrId(q.sm.options.QueueCollectionName)
+		if err != nil {
+			return fmt.Errorf("queue collection not found: %w", err)
+		}
+
+		requested := pbtypes.NowDateTime()
+		for _, host := range hosts {
+			job, err := txApp.FindFirstRecordByData(collection, "host", host.Id)
+			if err != nil {
+				job = core.NewRecord(collection)
+				job.Set("host", host.Id)
+			}
+
+			job.Set("requested", requested)
+			job.Set("revision", job.GetInt("revision")+1)
+			if err := txApp.Save(job); err != nil {
+				return fmt.Errorf("host %s: %w", host.Id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// schedule starts the debounce window of a batch of hosts.
+// Debouncing batches sharing a host with it are merged into it.
+func (q *regenerationQueue) schedule(hostIDs []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.running {
+		return
+	}
+
+	batch := &regenerationBatch{deadline: time.Now().Add(q.debounce)}
+	seen := map[string]bool{}
+	add := func(hostID string) {
+		if !seen[hostID] {
+			seen[hostID] = true
+			batch.hosts = append(batch.hosts, hostID)
+		}
+	}
+
+	for _, hostID := range hostIDs {
+		if existing, ok := q.pending[hostID]; ok && !existing.merged {
+			existing.merged = true
+			existing.timer.Stop()
+			if existing.deadline.Before(batch.deadline) {
+				batch.deadline = existing.deadline
+			}
+			for _, merged := range existing.hosts {
+				add(merged)
+			}
+		}
+		add(hostID)
+	}
+	for _, hostID := range batch.hosts {
+		q.pending[hostID] = batch
+	}
+
+	ready, stop := q.ready, q.stop
+	batch.timer = time.AfterFunc(time.Until(batch.deadline), func() {
+		q.mu.Lock()
+		if batch.merged {
+			q.mu.Unlock()
+			return
+		}
+		for _, hostID := range batch.hosts {
+			if q.pending[hostID] == batch {
+				delete(q.pending, hostID)
+			}
+		}
+		q.mu.Unlock()
+
+		select {
+		case ready <- batch.hosts:
+		case <-stop:
+		}
+	})
+}
+
+// work regenerates batches until the queue stops.
+func (q *regenerationQueue) work(ready <-chan []string, stop <-chan struct{}) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case hostIDs := <-ready:
+			q.process(hostIDs)
+		}
+	}
+}
+
+// process regenerates and saves the configs of a batch in one transaction, then completes its jobs.
+// A failure is logged and no host of the batch is changed, like synchronous regeneration.
+// A host missing from this tenant's collection is logged and its job deleted.
+func (q *regenerationQueue) process(hostIDs []string) {
+	q.mu.Lock()
+	q.active += len(hostIDs)
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.active -= len(hostIDs)
+		q.mu.Unlock()
+	}()
+
+	// Jobs are read before regenerating, so a request arriving meanwhile keeps its job
+	var jobs []*core.Record
+	var regenerated []string
+	err := q.sm.app.RunInTransaction(func(txApp core.App) error {
+		tx := q.sm.withApp(txApp)
+
+		for _, hostID := range hostIDs {
+			if job, err := txApp.FindFirstRecordByData(q.sm.options.QueueCollectionName, "host", hostID); err == nil {
+				jobs = append(jobs, job)
+			}
+
+			host, err := txApp.FindRecordById(q.sm.options.HostCollectionName, hostID)
+			if err != nil {
+				q.sm.logger.Warning("Host %s not found in %s, dropping its regeneration job: %v", hostID, q.sm.options.HostCollectionName, err)
+				continue
+			}
+
+			if err := tx.refreshHostConfig(host); err != nil {
+				return fmt.Errorf("host %s: %w", host.GetString("hostname"), err)
+			}
+			if err := txApp.Save(host); err != nil {
+				return fmt.Errorf("failed to save host %s: %w", host.GetString("hostname"), err)
+			}
+			regenerated = append(regenerated, host.GetString("hostname"))
+		}
+		return nil
+	})
+	if err != nil {
+		q.sm.logger.Warning("Failed to regenerate queued configs for %d hosts, no host was changed: %v", len(hostIDs), err)
+	} else {
+		for _, hostname := range regenerated {
+			q.sm.logger.Config("Regenerated config for host %s", hostname)
+		}
+	}
+
+	// Failed batches complete too, the hosts keep their last good configs
+	for _, job := range jobs {
+		q.complete(job)
+	}
+}
+
+// complete deletes a job unless the host was queued again while it was being regenerated.
+func (q *regenerationQueue) complete(job *core.Record) {
+	if job == nil {
+		return
+	}
+
+	q.persistMu.Lock()
+	defer q.persistMu.Unlock()
+
+	latest, err := q.sm.app.FindRecordById(q.sm.options.QueueCollectionName, job.Id)
+	if err != nil {
+		return // Already gone (e.g. deleted with its host)
+	}
+	if latest.GetInt("revision") != job.GetInt("revision") {
+		return // Requeued - the newer request runs after its debounce window
+	}
+
+	if err := q.sm.app.Delete(latest); err != nil {
+		q.sm.logger.Warning("Failed to delete regeneration job for host %s: %v", job.GetString("host"), err)
+	}
+}
@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/skeeeon/pb-nebula/internal/cert"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
+	"gopkg.in/yaml.v3"
+)
+
+// ReachabilityQuery asks whether one host can reach another on a protocol and port.
+type ReachabilityQuery struct {
+	FromHostID string `json:"from"`  // Initiating host record ID (A)
+	ToHostID   string `json:"to"`    // Destination host record ID (B)
+	Proto      string `json:"proto"` // tcp, udp or icmp
+	Port       int    `json:"port"`  // Destination port (ignored for icmp)
+}
+
+// CheckReachability answers "can host A reach host B on PROTO/PORT?".
+// The evaluation uses what Nebula will actually enforce: the firewall sections of the
+// generated configs (so policies, rule sets, templates and overrides are all included)
+// and the identities embedded in the host certificates.
+//
+// PARAMETERS:
+//   - query: Host pair, protocol and port to check
+//
+// RETURNS:
+// - firewall.Verdict explaining which rule matched (or why nothing did) in each direction
+// - error if a host is missing, has no certificate/config yet, or the query is invalid
+func (sm *Manager) CheckReachability(query ReachabilityQuery) (*firewall.Verdict, error) {
+	from, err := sm.app.FindRecordById(sm.options.HostCollectionName, query.FromHostID)
+	if err != nil {
+		return nil, fmt.Errorf("host %s not found: %w", query.FromHostID, err)
+	}
+	to, err := sm.app.FindRecordById(sm.options.HostCollectionName, query.ToHostID)
+	if err != nil {
+		return nil, fmt.Errorf("host %s not found: %w", query.ToHostID, err)
+	}
+
+	fromPeer, err := sm.firewallPeer(from)
+	if err != nil {
+		return nil, err
+	}
+	toPeer, err := sm.firewallPeer(to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromOutbound, _, err := generatedFirewallRules(from)
+	if err != nil {
+		return nil, err
+	}
+	_, toInbound, err := generatedFirewallRules(to)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict, err := firewall.Simulate(fromPeer, toPeer, fromOutbound, toInbound, query.Proto, query.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hosts in different networks never learn about each other through lighthouses
+	if from.GetString("network_id") != to.GetString("network_id") {
+		verdict.Allowed = false
+		verdict.Explanation = fmt.Sprintf("%s cannot reach %s: hosts are in different networks", fromPeer.Name, toPeer.Name)
+	}
+
+	return verdict, nil
+}
+
+// firewallPeer builds the firewall identity of a host from its certificate.
+func (sm *Manager) firewallPeer(host *core.Record) (firewall.Peer, error) {
+	info, err := sm.certManager.ParseCertificate(host.GetString("certificate"))
+	if err != nil {
+		return firewall.Peer{}, fmt.Errorf("host %s has no usable certificate: %w", host.GetString("hostname"), err)
+	}
+
+	peer := firewall.Peer{
+		Name:   info.Name,
+		IP:     host.GetString("overlay_ip"),
+		Groups: info.Groups,
+		CASha:  info.Issuer,
+	}
+
+	// ca_name rules match the name in the signing CA certificate
+	caInfo, err := sm.findSigningCA(info.Issuer)
+	if err != nil {
+		return firewall.Peer{}, fmt.Errorf("host %s: %w", host.GetString("hostname"), err)
+	}
+	peer.CAName = caInfo.Name
+
+	return peer, nil
+}
+
+// findSigningCA returns the certificate of the CA with the given fingerprint.
+// CAs created before the fingerprint field existed have it empty, so every
+// CA certificate is parsed when the indexed lookup finds nothing.
+func (sm *Manager) findSigningCA(fingerprint string) (*cert.CertificateInfo, error) {
+	if ca, err := sm.app.FindFirstRecordByData(sm.options.CACollectionName, "fingerprint", fingerprint); err == nil {
+		return sm.certManager.ParseCertificate(ca.GetString("certificate"))
+	}
+
+	cas, err := sm.app.FindAllRecords(sm.options.CACollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find CAs: %w", err)
+	}
+	for _, ca := range cas {
+		info, err := sm.certManager.ParseCertificate(ca.GetString("certificate"))
+		if err == nil && info.Fingerprint == fingerprint {
+			return info, nil
+		}
+	}
+
+	return nil, fmt.Errorf("signing CA %s not found", fingerprint)
+}
+
+// generatedFirewallRules extracts the firewall rules from a host's generated config.
+func generatedFirewallRules(host *core.Record) (outbound, inbound []map[string]interface{}, err error) {
+	configYAML := host.GetString("config_yaml")
+	if configYAML == "" {
+		return nil, nil, fmt.Errorf("host %s has no generated config yet", host.GetString("hostname"))
+	}
+
+	var generated struct {
+		Firewall struct {
+			Outbound []map[string]interface{} `yaml:"outbound"`
+			Inbound  []map[string]interface{} `yaml:"inbound"`
+		} `yaml:"firewall"`
+	}
+	if err := yaml.Unmarshal([]byte(configYAML), &generated); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse generated config of host %s: %w", host.GetString("hostname"), err)
+	}
+
+	return generated.Firewall.Outbound, generated.Firewall.Inbound, nil
+}
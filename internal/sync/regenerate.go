@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RegenerateRequest selects the hosts of a bulk regeneration and what to do with them.
+// An empty HostID and NetworkID selects every host.
+type RegenerateRequest struct {
+	HostID    string `json:"host_id"`    // Regenerate a single host
+	NetworkID string `json:"network_id"` // Regenerate every host in a network
+	Resign    bool   `json:"resign"`     // Re-sign certificates (keeping keys when possible), not just configs
+	DryRun    bool   `json:"dry_run"`    // Generate and validate everything, save nothing
+}
+
+// RegenerateResult is the outcome for a single host.
+type RegenerateResult struct {
+	HostID   string `json:"host_id"`         // Host record ID
+	Hostname string `json:"hostname"`        // Host name
+	Changed  bool   `json:"changed"`         // Config or certificate differs from the stored one
+	Error    string `json:"error,omitempty"` // Why the host failed (nothing was saved for it)
+}
+
+// RegenerateReport summarizes a bulk regeneration.
+type RegenerateReport struct {
+	DryRun    bool               `json:"dry_run"`   // Nothing was saved
+	Resign    bool               `json:"resign"`    // Certificates were re-signed
+	Total     int                `json:"total"`     // Hosts selected
+	Succeeded int                `json:"succeeded"` // Hosts regenerated (or that would be)
+	Changed   int                `json:"changed"`   // Succeeded hosts whose config or certificate changed
+	Failed    int                `json:"failed"`    // Hosts that failed
+	Hosts     []RegenerateResult `json:"hosts"`     // Per-host results, in processing order
+}
+
+// RegenerateProgress is called after each host with the number of hosts done so far.
+type RegenerateProgress func(done, total int, result RegenerateResult)
+
+// Regenerate force-regenerates configs, or re-signs certificates, of a host, a network
+// or the whole deployment. Hooks only rebuild configs when records change, so this
+// is the way to apply new generator defaults or a pb-nebula upgrade to existing hosts.
+//
+// DRY RUN:
+// Every host is generated and validated in memory exactly like a real run,
+// but nothing is saved. Changed shows which hosts a real run would update.
+//
+// FAILURE HANDLING:
+// A failing host doesn't stop the others and keeps its stored config and certificate.
+// Failures are listed in the report and joined into the returned error.
+//
+// PARAMETERS:
+//   - request: Scope (host, network or all), re-sign and dry-run flags
+//   - progress: Called after each host (nil for no progress reporting)
+//
+// RETURNS:
+// - RegenerateReport with per-host results (also returned alongside host failures)
+// - error if the scope cannot be loaded or any host failed
+func (sm *Manager) Regenerate(request RegenerateRequest, progress RegenerateProgress) (*RegenerateReport, error) {
+	hosts, err := sm.regenerateScope(request)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RegenerateReport{
+		DryRun: request.DryRun,
+		Resign: request.Resign,
+		Total:  len(hosts),
+		Hosts:  make([]RegenerateResult, 0, len(hosts)),
+	}
+
+	var failures []error
+	for i, host := range hosts {
+		result := RegenerateResult{
+			HostID:   host.Id,
+			Hostname: host.GetString("hostname"),
+		}
+
+		changed, err := sm.regenerateHost(host, request)
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			failures = append(failures, fmt.Errorf("host %s: %w", result.Hostname, err))
+		} else {
+			result.Changed = changed
+			report.Succeeded++
+			if changed {
+				report.Changed++
+			}
+		}
+
+		report.Hosts = append(report.Hosts, result)
+		if progress != nil {
+			progress(i+1, len(hosts), result)
+		}
+	}
+
+	action := "Regenerated"
+	if request.DryRun {
+		action = "Dry run: would regenerate"
+	}
+	sm.logger.Success("%s %d/%d hosts (%d changed, %d failed)", action, report.Succeeded, report.Total, report.Changed, report.Failed)
+
+	return report, errors.Join(failures...)
+}
+
+// regenerateScope loads the hosts selected by a regeneration request.
+func (sm *Manager) regenerateScope(request RegenerateRequest) ([]*core.Record, error) {
+	switch {
+	case request.HostID != "" && request.NetworkID != "":
+		return nil, fmt.Errorf("select a host or a network, not both")
+	case request.HostID != "":
+		host, err := sm.app.FindRecordById(sm.options.HostCollectionName, request.HostID)
+		if err != nil {
+			return nil, fmt.Errorf("host not found: %w", err)
+		}
+		return []*core.Record{host}, nil
+	case request.NetworkID != "":
+		if _, err := sm.app.FindRecordById(sm.options.NetworkCollectionName, request.NetworkID); err != nil {
+			return nil, fmt.Errorf("network not found: %w", err)
+		}
+		hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName,
+			dbx.HashExp{"network_id": request.NetworkID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find hosts in network: %w", err)
+		}
+		return hosts, nil
+	default:
+		hosts, err := sm.app.FindAllRecords(sm.options.HostCollectionName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find hosts: %w", err)
+		}
+		return hosts, nil
+	}
+}
+
+// regenerateHost regenerates a single host and saves it unless this is a dry run.
+// Generation works on a copy, so a failing host is left untouched.
+func (sm *Manager) regenerateHost(host *core.Record, request RegenerateRequest) (bool, error) {
+	updated := host.Clone()
+
+	var err error
+	if request.Resign {
+		err = sm.resignHostCertAndConfig(updated)
+	} else {
+		err = sm.generateHostConfig(updated)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	changed := updated.GetString("config_yaml") != host.GetString("config_yaml") ||
+		updated.GetString("certificate") != host.GetString("certificate")
+	if request.DryRun {
+		return changed, nil
+	}
+
+	if err := sm.app.Save(updated); err != nil {
+		return false, fmt.Errorf("failed to save host: %w", err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,225 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ErrConfigsNotRegenerated is returned (wrapped) together with the rotation results
+// when the keys were rotated but the network configs carrying the new blocklist
+// couldn't be regenerated. The rotation stays saved, the old certificates are only
+// rejected once the configs are regenerated (e.g. with `nebula doctor --repair`).
+var ErrConfigsNotRegenerated = errors.New("keys rotated, but network configs were not regenerated")
+
+// KeyRotationResult describes the outcome of rotating a single host's keys.
+type KeyRotationResult struct {
+	HostID         string `json:"host_id"`         // Host record ID
+	Hostname       string `json:"hostname"`        // Host name
+	OldFingerprint string `json:"old_fingerprint"` // Fingerprint of the retired (now blocklisted) certificate
+	NewFingerprint string `json:"new_fingerprint"` // Fingerprint of the newly issued certificate
+	KeyGeneration  int    `json:"key_generation"`  // Key generation counter after rotation
+}
+
+// RotateHostKeys deliberately re-keys a single host.
+// Unlike routine re-signing, this generates a NEW key pair and retires the old certificate.
+//
+// ROTATION STEPS:
+// 1. Generate new key pair and certificate (config regenerated too)
+// 2. Increment key_generation on the host record
+// 3. Add the old certificate fingerprint to the network blocklist
+// 4. Regenerate configs for every host in the network (distributes the blocklist)
+//
+// Steps 1-3 are saved in one transaction, so the host never gets new keys
+// while its old certificate stays off the blocklist.
+//
+// USE CASE:
+// Compromised machines - the stolen key and certificate stop working on every
+// host as soon as the new configs are picked up.
+//
+// PARAMETERS:
+//   - hostID: Database ID of the host to rotate
+//
+// RETURNS:
+// - KeyRotationResult describing old and new certificates
+// - error if the host cannot be found or rotation fails
+// - KeyRotationResult and an error wrapping ErrConfigsNotRegenerated if only step 4 failed
+func (sm *Manager) RotateHostKeys(hostID string) (*KeyRotationResult, error) {
+	var network *core.Record
+	var result *KeyRotationResult
+	err := sm.app.RunInTransaction(func(txApp core.App) error {
+		tx := sm.withApp(txApp)
+
+		// Records are read in the transaction, so edits made meanwhile aren't overwritten
+		host, err := txApp.FindRecordById(sm.options.HostCollectionName, hostID)
+		if err != nil {
+			return fmt.Errorf("host not found: %w", err)
+		}
+		network, err = txApp.FindRecordById(sm.options.NetworkCollectionName, host.GetString("network_id"))
+		if err != nil {
+			return fmt.Errorf("network not found: %w", err)
+		}
+
+		if result, err = tx.rotateHostKeys(host); err != nil {
+			return err
+		}
+		if err := tx.blocklistFingerprints(network, []string{result.OldFingerprint}); err != nil {
+			return fmt.Errorf("failed to blocklist old certificate: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, sm.distributeBlocklist(network)
+}
+
+// RotateNetworkKeys re-keys every host in a network.
+// Each host gets a new key pair, all old fingerprints are blocklisted at once,
+// and network configs are regenerated a single time at the end.
+//
+// FAILURE HANDLING:
+// The rotations and the blocklist are saved in one transaction. A failing host
+// fails the whole rotation: no host gets new keys and the blocklist is unchanged.
+//
+// PARAMETERS:
+//   - networkID: Database ID of the network to rotate
+//
+// RETURNS:
+// - []KeyRotationResult for every rotated host
+// - error if the network cannot be found or any host failed
+// - []KeyRotationResult and an error wrapping ErrConfigsNotRegenerated if only the config regeneration failed
+func (sm *Manager) RotateNetworkKeys(networkID string) ([]KeyRotationResult, error) {
+	var network *core.Record
+	var results []KeyRotationResult
+	err := sm.app.RunInTransaction(func(txApp core.App) error {
+		tx := sm.withApp(txApp)
+
+		// Records are read in the transaction, so edits made meanwhile aren't overwritten
+		var err error
+		network, err = txApp.FindRecordById(sm.options.NetworkCollectionName, networkID)
+		if err != nil {
+			return fmt.Errorf("network not found: %w", err)
+		}
+		hosts, err := tx.findNetworkHosts(network)
+		if err != nil {
+			return err
+		}
+
+		results = make([]KeyRotationResult, 0, len(hosts))
+		oldFingerprints := make([]string, 0, len(hosts))
+		for _, host := range hosts {
+			result, err := tx.rotateHostKeys(host)
+			if err != nil {
+				return fmt.Errorf("host %s: %w", host.Id, err)
+			}
+			results = append(results, *result)
+			oldFingerprints = append(oldFingerprints, result.OldFingerprint)
+		}
+
+		if err := tx.blocklistFingerprints(network, oldFingerprints); err != nil {
+			return fmt.Errorf("failed to blocklist old certificates: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		sm.logger.Warning("Failed to rotate keys in network %s, no host was changed: %v", networkID, err)
+		return nil, err
+	}
+
+	sm.logger.Success("Rotated keys for %d hosts in network %s", len(results), network.GetString("name"))
+
+	return results, sm.distributeBlocklist(network)
+}
+
+// distributeBlocklist regenerates every host config of a network after a rotation.
+// It bypasses the regeneration queue, so the caller learns whether the new blocklist
+// reached the configs.
+func (sm *Manager) distributeBlocklist(network *core.Record) error {
+	hosts, err := sm.findNetworkHosts(network)
+	if err == nil {
+		err = sm.saveHostConfigs(hosts, "network "+network.GetString("name"))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConfigsNotRegenerated, err)
+	}
+	return nil
+}
+
+// rotateHostKeys generates a new key pair and certificate for a host and saves it.
+// The network blocklist is NOT updated here - callers batch that.
+func (sm *Manager) rotateHostKeys(host *core.Record) (*KeyRotationResult, error) {
+	hostname := host.GetString("hostname")
+	sm.logger.Cert("Rotating keys for host %s...", hostname)
+
+	// Older records may not have a stored fingerprint, derive it from the certificate
+	oldFingerprint := host.GetString("fingerprint")
+	if oldFingerprint == "" && host.GetString("certificate") != "" {
+		if info, err := sm.certManager.ParseCertificate(host.GetString("certificate")); err == nil {
+			oldFingerprint = info.Fingerprint
+		}
+	}
+
+	if err := sm.generateHostCertAndConfig(host); err != nil {
+		return nil, fmt.Errorf("failed to generate new keys: %w", err)
+	}
+
+	keyGeneration := host.GetInt("key_generation") + 1
+	host.Set("key_generation", keyGeneration)
+
+	if err := sm.app.Save(host); err != nil {
+		return nil, fmt.Errorf("failed to save host record: %w", err)
+	}
+
+	sm.logger.Success("Rotated keys for host %s (generation %d)", hostname, keyGeneration)
+
+	return &KeyRotationResult{
+		HostID:         host.Id,
+		Hostname:       hostname,
+		OldFingerprint: oldFingerprint,
+		NewFingerprint: host.GetString("fingerprint"),
+		KeyGeneration:  keyGeneration,
+	}, nil
+}
+
+// blocklistFingerprints appends fingerprints to the network blocklist and saves the network.
+// Empty and already blocklisted fingerprints are skipped.
+//
+// The network is saved without hooks: callers regenerate host configs explicitly,
+// so the network update hook would only repeat that work. Callers read the network
+// in the same transaction, so the save can't overwrite a concurrent edit.
+func (sm *Manager) blocklistFingerprints(network *core.Record, fingerprints []string) error {
+	var blocklist []string
+	blocklistJSON := network.GetString("blocklist")
+	if blocklistJSON != "" && blocklistJSON != "null" {
+		if err := json.Unmarshal([]byte(blocklistJSON), &blocklist); err != nil {
+			return fmt.Errorf("failed to parse blocklist: %w", err)
+		}
+	}
+
+	added := 0
+	for _, fingerprint := range fingerprints {
+		if fingerprint == "" || slices.Contains(blocklist, fingerprint) {
+			continue
+		}
+		blocklist = append(blocklist, fingerprint)
+		added++
+	}
+
+	if added == 0 {
+		return nil
+	}
+
+	network.Set("blocklist", blocklist)
+	if err := sm.app.UnsafeWithoutHooks().Save(network); err != nil {
+		return fmt.Errorf("failed to save network: %w", err)
+	}
+
+	sm.logger.Cert("Blocklisted %d certificate(s) in network %s", added, network.GetString("name"))
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Public endpoint errors.
+// Re-exported by the pbnebula package so callers can match them with errors.Is.
+var (
+	ErrInvalidEndpoint      = errors.New("invalid public endpoint")
+	ErrEndpointMissingPort  = errors.New("public endpoint missing port")
+	ErrInvalidEndpointPort  = errors.New("invalid public endpoint port")
+	ErrInvalidEndpointHost  = errors.New("invalid public endpoint host")
+	ErrLighthouseNoPublicIP = errors.New("lighthouse hosts require public_host_port or public_endpoints")
+	ErrRelayNoPublicIP      = errors.New("relay hosts require public_host_port or public_endpoints")
+)
+
+// Endpoint is a parsed public endpoint (HOST:PORT) of a lighthouse or relay.
+//
+// SUPPORTED FORMATS:
+// - IPv4: "203.0.113.10:4242"
+// - IPv6: "[2001:db8::1]:4242" (brackets required, as in Nebula's static_host_map)
+// - DNS name: "lighthouse.example.com:4242" (resolved by Nebula)
+type Endpoint struct {
+	Host string // IP address or DNS name (without brackets)
+	Port int    // UDP port (1-65535)
+}
+
+// String returns the endpoint in canonical HOST:PORT form, bracketing IPv6 addresses.
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// IsIP reports whether the endpoint host is an IP address (not a DNS name).
+func (e Endpoint) IsIP() bool {
+	return net.ParseIP(e.Host) != nil
+}
+
+// ParseEndpoint parses and validates a public endpoint string.
+//
+// VALIDATION CHECKS:
+// - HOST:PORT format (net.SplitHostPort, so IPv6 must be bracketed)
+// - Port is numeric and within 1-65535
+// - Host is an IP address or a valid DNS name
+//
+// PARAMETERS:
+//   - raw: Endpoint string (e.g., "[2001:db8::1]:4242")
+//
+// RETURNS:
+// - Endpoint with host and port split out
+// - error wrapping one of the endpoint errors above
+func ParseEndpoint(raw string) (Endpoint, error) {
+	return parseEndpoint(raw, false)
+}
+
+// ParseAdvertiseAddr parses and validates an address for lighthouse.advertise_addrs.
+// Nebula only accepts IP addresses here, and port 0 means "use the listen port".
+//
+// PARAMETERS:
+//   - raw: Address string (e.g., "203.0.113.20:4242", "[2001:db8::20]:0")
+//
+// RETURNS:
+// - Endpoint with host and port split out
+// - error wrapping one of the endpoint errors above
+func ParseAdvertiseAddr(raw string) (Endpoint, error) {
+	endpoint, err := parseEndpoint(raw, true)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	if !endpoint.IsIP() {
+		return Endpoint{}, fmt.Errorf("%w: %q (advertised addresses must be IP addresses)", ErrInvalidEndpointHost, raw)
+	}
+	return endpoint, nil
+}
+
+// parseEndpoint implements ParseEndpoint and ParseAdvertiseAddr.
+func parseEndpoint(raw string, allowZeroPort bool) (Endpoint, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return Endpoint{}, fmt.Errorf("%w: empty endpoint", ErrInvalidEndpoint)
+	}
+
+	host, portStr, err := net.SplitHostPort(trimmed)
+	if err != nil {
+		// Bare IPs and names without a port are the common mistake - say so explicitly
+		if ip := net.ParseIP(strings.Trim(trimmed, "[]")); ip != nil && ip.To4() == nil {
+			return Endpoint{}, fmt.Errorf("%w: %q (IPv6 endpoints must be written as [ADDRESS]:PORT)", ErrEndpointMissingPort, raw)
+		}
+		if !strings.Contains(trimmed, ":") || strings.HasPrefix(trimmed, "[") {
+			return Endpoint{}, fmt.Errorf("%w: %q", ErrEndpointMissingPort, raw)
+		}
+		return Endpoint{}, fmt.Errorf("%w: %q: %v", ErrInvalidEndpoint, raw, err)
+	}
+
+	minPort := 1
+	if allowZeroPort {
+		minPort = 0
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < minPort || port > 65535 {
+		return Endpoint{}, fmt.Errorf("%w: %q (port must be %d-65535)", ErrInvalidEndpointPort, raw, minPort)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsUnspecified() {
+			return Endpoint{}, fmt.Errorf("%w: %q (unspecified address is not reachable)", ErrInvalidEndpointHost, raw)
+		}
+		return Endpoint{Host: host, Port: port}, nil
+	}
+
+	if !isDNSName(host) {
+		return Endpoint{}, fmt.Errorf("%w: %q (must be an IP address or DNS name)", ErrInvalidEndpointHost, raw)
+	}
+
+	return Endpoint{Host: host, Port: port}, nil
+}
+
+// isDNSName reports whether s is a syntactically valid DNS name (RFC 1123 labels).
+// A single trailing dot (fully qualified name) is allowed.
+func isDNSName(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+			if !isAlnum && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
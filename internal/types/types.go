@@ -3,6 +3,9 @@ package types
 
 import (
 	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -19,6 +22,10 @@ import (
 // KEY STORAGE:
 // Private key is stored as plaintext in a HIDDEN field (same philosophy as pb-nats).
 // The field is not exposed via PocketBase API but is accessible internally.
+//
+// CA CONSTRAINTS:
+// Optional allowed networks/groups are embedded in the CA certificate.
+// Nebula rejects host certificates outside them, so pb-nebula checks hosts up front.
 type CARecord struct {
 	ID            string    `json:"id"`             // Database primary key
 	Name          string    `json:"name"`           // Human-readable CA name
@@ -27,8 +34,15 @@ type CARecord struct {
 	ValidityYears int       `json:"validity_years"` // Certificate validity period
 	ExpiresAt     time.Time `json:"expires_at"`     // Certificate expiration timestamp
 	Curve         string    `json:"curve"`          // Always "CURVE25519" for now
-	Created       time.Time `json:"created"`        // Creation timestamp
-	Updated       time.Time `json:"updated"`        // Last update timestamp
+	Fingerprint   string    `json:"fingerprint"`    // SHA256 fingerprint of the CA certificate
+	Issuer        string    `json:"issuer"`         // Always empty (self-signed)
+
+	// Optional signing constraints (embedded in the CA certificate, fixed after generation)
+	AllowedNetworks string `json:"allowed_networks"` // JSON array of CIDRs host IPs must fall within
+	AllowedGroups   string `json:"allowed_groups"`   // JSON array of groups hosts may carry
+
+	Created time.Time `json:"created"` // Creation timestamp
+	Updated time.Time `json:"updated"` // Last update timestamp
 }
 
 // NetworkRecord represents a Nebula network providing isolation for hosts.
@@ -45,14 +59,26 @@ type CARecord struct {
 // - Default is DENY-ALL
 // - See HostRecord for firewall rule fields
 type NetworkRecord struct {
-	ID          string    `json:"id"`          // Database primary key
-	Name        string    `json:"name"`        // Human-readable network name
-	CIDRRange   string    `json:"cidr_range"`  // IPv4 CIDR (e.g., "10.128.0.0/16")
-	Description string    `json:"description"` // Network description
-	CAID        string    `json:"ca_id"`       // Relation to nebula_ca
-	Active      bool      `json:"active"`      // Network enable/disable flag
-	Created     time.Time `json:"created"`     // Creation timestamp
-	Updated     time.Time `json:"updated"`     // Last update timestamp
+	ID              string `json:"id"`               // Database primary key
+	Name            string `json:"name"`             // Human-readable network name
+	CIDRRange       string `json:"cidr_range"`       // IPv4 CIDR (e.g., "10.128.0.0/16")
+	Description     string `json:"description"`      // Network description
+	CAID            string `json:"ca_id"`            // Relation to nebula_ca
+	Blocklist       string `json:"blocklist"`        // JSON array of blocklisted certificate fingerprints
+	ConfigOverrides string `json:"config_overrides"` // JSON object merged over generated host configs
+	TemplateID      string `json:"template_id"`      // Optional relation to nebula_templates
+	Active          bool   `json:"active"`           // Network enable/disable flag
+
+	// Default firewall rules for hosts of this network (empty mode = Options default)
+	FirewallDefaultMode     string `json:"firewall_default_mode"`     // "recommended", "none", "custom" or empty
+	FirewallDefaultOutbound string `json:"firewall_default_outbound"` // JSON array of custom outbound defaults
+	FirewallDefaultInbound  string `json:"firewall_default_inbound"`  // JSON array of custom inbound defaults
+
+	// Firewall actions and conntrack timeouts (hosts override per key)
+	FirewallSettings string `json:"firewall_settings"` // JSON object (see FirewallSettings)
+
+	Created time.Time `json:"created"` // Creation timestamp
+	Updated time.Time `json:"updated"` // Last update timestamp
 }
 
 // HostRecord represents a Nebula host with PocketBase authentication integration.
@@ -87,23 +113,45 @@ type HostRecord struct {
 	OverlayIP string `json:"overlay_ip"` // Overlay network IP (e.g., "10.128.0.100")
 	Groups    string `json:"groups"`     // JSON array of group names for firewall rules
 
-	// Lighthouse configuration
-	IsLighthouse   bool   `json:"is_lighthouse"`    // True if this host is a lighthouse
-	PublicHostPort string `json:"public_host_port"` // Public IP:PORT (required if lighthouse)
+	// Lighthouse and relay configuration
+	IsLighthouse    bool   `json:"is_lighthouse"`    // True if this host is a lighthouse
+	IsRelay         bool   `json:"is_relay"`         // True if this host relays traffic for other hosts
+	PublicHostPort  string `json:"public_host_port"` // Primary public IP:PORT (lighthouse/relay need this or public_endpoints)
+	PublicEndpoints string `json:"public_endpoints"` // JSON array of additional public endpoints (IPv6, DNS names, alternate ports)
+
+	// Listen settings (honoured for every host type)
+	ListenHost     string `json:"listen_host"`     // UDP listen IP (empty = 0.0.0.0)
+	ListenPort     int    `json:"listen_port"`     // UDP listen port (0 = primary endpoint port for lighthouses/relays, random otherwise)
+	AdvertiseAddrs string `json:"advertise_addrs"` // JSON array of IP:PORT reported to lighthouses (lighthouse.advertise_addrs)
+
+	// Lighthouse DNS server (answers overlay queries for certificate hostnames)
+	ServeDNS bool   `json:"serve_dns"` // True if this lighthouse serves DNS
+	DNSHost  string `json:"dns_host"`  // DNS listen IP (empty = overlay IP)
+	DNSPort  int    `json:"dns_port"`  // DNS listen port (0 = 53)
 
 	// Generated Nebula credentials
 	Certificate   string `json:"certificate"`    // PEM encoded host certificate
 	PrivateKey    string `json:"private_key"`    // PEM encoded host private key
 	CACertificate string `json:"ca_certificate"` // PEM encoded CA cert (denormalized for convenience)
 	ConfigYAML    string `json:"config_yaml"`    // Complete Nebula config ready to use
+	Fingerprint   string `json:"fingerprint"`    // SHA256 fingerprint of the host certificate
+	Issuer        string `json:"issuer"`         // Fingerprint of the signing CA
 
 	// Host-specific firewall rules (Nebula native JSON format)
-	FirewallOutbound string `json:"firewall_outbound"` // JSON array of outbound firewall rules
-	FirewallInbound  string `json:"firewall_inbound"`  // JSON array of inbound firewall rules
+	FirewallOutbound string   `json:"firewall_outbound"` // JSON array of outbound firewall rules
+	FirewallInbound  string   `json:"firewall_inbound"`  // JSON array of inbound firewall rules
+	FirewallRuleSets []string `json:"firewall_rulesets"` // Relations to nebula_firewall_rulesets (applied before inline rules)
+	FirewallFindings string   `json:"firewall_findings"` // JSON array of firewall lint findings (auto-generated)
+	FirewallSettings string   `json:"firewall_settings"` // JSON object of firewall actions/conntrack (wins over network per key)
+
+	// Host-specific Nebula config overrides (take precedence over network overrides)
+	ConfigOverrides string `json:"config_overrides"` // JSON object merged over the generated config
+	TemplateID      string `json:"template_id"`      // Optional relation to nebula_templates (wins over network template)
 
 	// Certificate validity
 	ValidityYears int       `json:"validity_years"` // Certificate validity period
 	ExpiresAt     time.Time `json:"expires_at"`     // Certificate expiration timestamp
+	KeyGeneration int       `json:"key_generation"` // Incremented on every explicit key rotation
 
 	// Management flags
 	Active  bool      `json:"active"`  // Host enable/disable flag
@@ -111,29 +159,150 @@ type HostRecord struct {
 	Updated time.Time `json:"updated"` // Last update timestamp
 }
 
+// TemplateRecord represents a user-supplied Nebula config template.
+// Templates replace the built-in config layout for the networks and hosts they are assigned to.
+//
+// TEMPLATE FORMAT:
+// Go text/template syntax producing a YAML document. Templates receive the host's
+// PKI material, lighthouses, firewall rules and overlay data (see config.TemplateData).
+//
+// ASSIGNMENT PRECEDENCE:
+// Host template > network template > built-in layout.
+// Config overrides are still merged over the rendered template.
+type TemplateRecord struct {
+	ID          string    `json:"id"`          // Database primary key
+	Name        string    `json:"name"`        // Human-readable template name (unique)
+	Description string    `json:"description"` // Template description
+	Content     string    `json:"content"`     // Go text/template source producing Nebula YAML
+	Created     time.Time `json:"created"`     // Creation timestamp
+	Updated     time.Time `json:"updated"`     // Last update timestamp
+}
+
+// FirewallPolicyRecord represents a network-level firewall policy.
+// Policies save admins from copying the same rules into many host records.
+//
+// POLICY COMPILATION:
+// Nebula firewalls are still host-based: each policy is compiled into the effective
+// rules of every host in its network that carries one of the target groups
+// (or every host if no target groups are set), in front of the host's own rules.
+//
+// EXAMPLE:
+// "group web accepts tcp/443 from group lb" is a policy with direction "inbound",
+// target_groups ["web"] and rules [{"port": "443", "proto": "tcp", "group": "lb"}].
+type FirewallPolicyRecord struct {
+	ID           string    `json:"id"`            // Database primary key
+	Name         string    `json:"name"`          // Human-readable policy name
+	Description  string    `json:"description"`   // Policy description
+	NetworkID    string    `json:"network_id"`    // Relation to nebula_networks
+	Direction    string    `json:"direction"`     // "inbound" or "outbound"
+	TargetGroups string    `json:"target_groups"` // JSON array of groups the policy applies to (empty = all hosts)
+	Rules        string    `json:"rules"`         // JSON array of rules in Nebula native format
+	Active       bool      `json:"active"`        // Policy enable/disable flag
+	Created      time.Time `json:"created"`       // Creation timestamp
+	Updated      time.Time `json:"updated"`       // Last update timestamp
+}
+
+// FirewallRuleSetRecord represents a named, reusable set of firewall rules (a security group).
+// Hosts reference rule sets by id in addition to their inline rules.
+//
+// VERSIONING:
+// Version starts at 1 and is incremented every time the rules change, so
+// admins can tell which revision of "ssh-from-admins" a host was generated with.
+//
+// FLEET-WIDE FIXES:
+// Editing a rule set regenerates the config of every host referencing it.
+type FirewallRuleSetRecord struct {
+	ID          string    `json:"id"`          // Database primary key
+	Name        string    `json:"name"`        // Human-readable rule set name (unique, e.g. "ssh-from-admins")
+	Description string    `json:"description"` // Rule set description
+	Outbound    string    `json:"outbound"`    // JSON array of outbound rules in Nebula native format
+	Inbound     string    `json:"inbound"`     // JSON array of inbound rules in Nebula native format
+	Version     int       `json:"version"`     // Incremented on every rule change
+	Created     time.Time `json:"created"`     // Creation timestamp
+	Updated     time.Time `json:"updated"`     // Last update timestamp
+}
+
+// FirewallDefaults selects the rules injected for a firewall direction that is left
+// without rules by the host, its rule sets and the network policies.
+//
+// MODES:
+// - recommended: Nebula recommendations (outbound allow all, inbound ICMP from any)
+// - none: No defaults - an empty direction denies everything
+// - custom: Outbound/Inbound below (an empty list denies that direction)
+type FirewallDefaults struct {
+	Mode     string                   `json:"mode"`     // One of the FirewallDefaults* modes (empty = recommended)
+	Outbound []map[string]interface{} `json:"outbound"` // Custom mode outbound defaults
+	Inbound  []map[string]interface{} `json:"inbound"`  // Custom mode inbound defaults
+}
+
+// FirewallSettings holds the Nebula firewall settings besides the rules.
+// Stored as a JSON object in firewall_settings on networks and hosts, mirroring
+// the Nebula firewall section, e.g. {"inbound_action": "reject", "conntrack": {"tcp_timeout": "2h"}}.
+//
+// INHERITANCE:
+// Unset fields inherit per key: host > network > Nebula default.
+type FirewallSettings struct {
+	OutboundAction      string            `json:"outbound_action,omitempty"`        // "drop" or "reject" (Nebula default: drop)
+	InboundAction       string            `json:"inbound_action,omitempty"`         // "drop" or "reject" (Nebula default: drop)
+	DefaultLocalCIDRAny *bool             `json:"default_local_cidr_any,omitempty"` // Rules without local_cidr match any local IP (Nebula default: false)
+	Conntrack           FirewallConntrack `json:"conntrack"`                        // Connection tracking timeouts
+}
+
+// FirewallConntrack holds Nebula connection tracking timeouts as Go durations (e.g., "12m").
+type FirewallConntrack struct {
+	TCPTimeout     string `json:"tcp_timeout,omitempty"`     // Idle TCP connections (Nebula default: 12m)
+	UDPTimeout     string `json:"udp_timeout,omitempty"`     // Idle UDP flows (Nebula default: 3m)
+	DefaultTimeout string `json:"default_timeout,omitempty"` // Other protocols, e.g. ICMP (Nebula default: 10m)
+}
+
 // LighthouseInfo contains the information needed to configure lighthouse discovery.
 // This is a helper structure used during config generation to build static host maps.
 //
 // LIGHTHOUSE DISCOVERY:
 // Non-lighthouse hosts need to know where lighthouses are located (public IP:PORT).
 // This information is used to build the static_host_map section in Nebula configs.
+//
+// OVERLAY DNS:
+// Lighthouses serving DNS are listed as resolvers in non-lighthouse configs.
 type LighthouseInfo struct {
-	OverlayIP      string `json:"overlay_ip"`       // Lighthouse overlay IP (e.g., "10.128.0.1")
-	PublicHostPort string `json:"public_host_port"` // Lighthouse public IP:PORT (e.g., "1.2.3.4:4242")
+	OverlayIP       string   `json:"overlay_ip"`       // Lighthouse overlay IP (e.g., "10.128.0.1")
+	PublicHostPort  string   `json:"public_host_port"` // Lighthouse primary public IP:PORT (e.g., "1.2.3.4:4242")
+	PublicEndpoints []string `json:"public_endpoints"` // All public endpoints, primary first
+	ServeDNS        bool     `json:"serve_dns"`        // True if the lighthouse serves DNS
+	DNSHost         string   `json:"dns_host"`         // DNS listen IP (empty = overlay IP)
+	DNSPort         int      `json:"dns_port"`         // DNS listen port (0 = 53)
 }
 
 // Options configures the behavior of Nebula certificate and config generation.
 // This is the main configuration structure passed to Setup().
 type Options struct {
 	// Collection names (customizable for different deployments)
-	CACollectionName      string // Default: "nebula_ca"
-	NetworkCollectionName string // Default: "nebula_networks"
-	HostCollectionName    string // Default: "nebula_hosts"
+	CACollectionName       string // Default: "nebula_ca"
+	NetworkCollectionName  string // Default: "nebula_networks"
+	HostCollectionName     string // Default: "nebula_hosts"
+	TemplateCollectionName string // Default: "nebula_templates"
+	PolicyCollectionName   string // Default: "nebula_firewall_policies"
+	RuleSetCollectionName  string // Default: "nebula_firewall_rulesets"
+	QueueCollectionName    string // Default: "nebula_regeneration_jobs"
 
 	// Certificate defaults
 	DefaultCAValidityYears   int // Default: 10 years
 	DefaultHostValidityYears int // Default: 1 year
 
+	// Default firewall rules for directions hosts leave empty (networks can override)
+	DefaultFirewallMode     string                   // Default: "recommended" ("none" = deny-by-default, "custom" = rules below)
+	DefaultFirewallOutbound []map[string]interface{} // Outbound defaults for "custom" mode
+	DefaultFirewallInbound  []map[string]interface{} // Inbound defaults for "custom" mode
+
+	// Clock skew tolerance - NotBefore of every issued certificate is moved this far
+	// into the past so hosts with slightly slow clocks accept new certificates.
+	CertificateBackdate time.Duration // Default: 5 minutes (0 uses the default, negative disables backdating)
+
+	// Regeneration queue - network-wide config regenerations run in the background,
+	// triggers for the same host within the debounce window are coalesced.
+	RegenerationDebounce time.Duration // Default: 2 seconds (0 processes triggers immediately)
+	RegenerationWorkers  int           // Default: 4 concurrent host regenerations
+
 	// Logging
 	LogToConsole bool // Enable console logging
 
@@ -144,9 +313,32 @@ type Options struct {
 
 // Collection names with nebula_ prefix for clear identification
 const (
-	DefaultCACollectionName      = "nebula_ca"       // CA certificate authority
-	DefaultNetworkCollectionName = "nebula_networks" // Network definitions
-	DefaultHostCollectionName    = "nebula_hosts"    // Host configurations (auth collection)
+	DefaultCACollectionName       = "nebula_ca"                // CA certificate authority
+	DefaultNetworkCollectionName  = "nebula_networks"          // Network definitions
+	DefaultHostCollectionName     = "nebula_hosts"             // Host configurations (auth collection)
+	DefaultTemplateCollectionName = "nebula_templates"         // User-supplied config templates
+	DefaultPolicyCollectionName   = "nebula_firewall_policies" // Network-level firewall policies
+	DefaultRuleSetCollectionName  = "nebula_firewall_rulesets" // Reusable firewall rule sets
+	DefaultQueueCollectionName    = "nebula_regeneration_jobs" // Pending config regenerations
+)
+
+// Firewall rule directions (firewall policy direction values)
+const (
+	FirewallDirectionInbound  = "inbound"
+	FirewallDirectionOutbound = "outbound"
+)
+
+// Firewall default modes (Options.DefaultFirewallMode, network firewall_default_mode)
+const (
+	FirewallDefaultsRecommended = "recommended" // Nebula recommended defaults
+	FirewallDefaultsNone        = "none"        // No defaults (deny-by-default)
+	FirewallDefaultsCustom      = "custom"      // User-supplied defaults
+)
+
+// Firewall actions for packets no rule allows (firewall_settings outbound_action/inbound_action)
+const (
+	FirewallActionDrop   = "drop"   // Silently discard (Nebula default)
+	FirewallActionReject = "reject" // Answer with TCP RST / ICMP unreachable (fails fast)
 )
 
 // Default validity periods
@@ -155,19 +347,214 @@ const (
 	DefaultHostValidityYears = 1  // 1 year for host certificates
 )
 
+// Default clock skew tolerance for certificate NotBefore
+const DefaultCertificateBackdate = 5 * time.Minute
+
+// Default regeneration queue settings
+const (
+	DefaultRegenerationDebounce = 2 * time.Second // Coalescing window per host
+	DefaultRegenerationWorkers  = 4               // Concurrent host regenerations
+)
+
 // Event types for logging and filtering
 // These constants enable consistent event classification across components
 const (
-	EventTypeCACreate      = "ca_create"      // CA creation events
-	EventTypeCAUpdate      = "ca_update"      // CA modification events
-	EventTypeNetworkCreate = "network_create" // Network creation events
-	EventTypeNetworkUpdate = "network_update" // Network modification events
-	EventTypeNetworkDelete = "network_delete" // Network deletion events
-	EventTypeHostCreate    = "host_create"    // Host creation events
-	EventTypeHostUpdate    = "host_update"    // Host modification events
-	EventTypeHostDelete    = "host_delete"    // Host deletion events
+	EventTypeCACreate       = "ca_create"       // CA creation events
+	EventTypeCAUpdate       = "ca_update"       // CA modification events
+	EventTypeNetworkCreate  = "network_create"  // Network creation events
+	EventTypeNetworkUpdate  = "network_update"  // Network modification events
+	EventTypeNetworkDelete  = "network_delete"  // Network deletion events
+	EventTypeHostCreate     = "host_create"     // Host creation events
+	EventTypeHostUpdate     = "host_update"     // Host modification events
+	EventTypeHostDelete     = "host_delete"     // Host deletion events
+	EventTypeTemplateUpdate = "template_update" // Config template modification events
+	EventTypePolicyCreate   = "policy_create"   // Firewall policy creation events
+	EventTypePolicyUpdate   = "policy_update"   // Firewall policy modification events
+	EventTypePolicyDelete   = "policy_delete"   // Firewall policy deletion events
+	EventTypeRuleSetUpdate  = "ruleset_update"  // Firewall rule set modification events
 )
 
+// GetBlocklist extracts the blocklisted certificate fingerprints from the JSON field.
+// Blocklisted fingerprints are rendered into pki.blocklist of every host in the network.
+//
+// RETURNS:
+// - []string containing certificate fingerprints
+// - error if JSON parsing fails
+//
+// EMPTY HANDLING:
+// Empty or null JSON returns empty slice (not error).
+func (n *NetworkRecord) GetBlocklist() ([]string, error) {
+	if n.Blocklist == "" || n.Blocklist == "null" {
+		return []string{}, nil
+	}
+
+	var blocklist []string
+	if err := json.Unmarshal([]byte(n.Blocklist), &blocklist); err != nil {
+		return nil, err
+	}
+	return blocklist, nil
+}
+
+// GetConfigOverrides extracts the network-wide config overrides from the JSON field.
+//
+// RETURNS:
+// - map[string]interface{} to merge over generated configs (nil if unset)
+// - error if the field is not a JSON object
+func (n *NetworkRecord) GetConfigOverrides() (map[string]interface{}, error) {
+	return ParseConfigOverrides(n.ConfigOverrides)
+}
+
+// GetFirewallSettings extracts the network-wide firewall settings from the JSON field.
+//
+// RETURNS:
+// - FirewallSettings (zero value if unset)
+// - error if the field is not a valid settings object
+func (n *NetworkRecord) GetFirewallSettings() (FirewallSettings, error) {
+	return ParseFirewallSettings(n.FirewallSettings)
+}
+
+// GetFirewallDefaults extracts the network's default firewall rules.
+//
+// RETURNS:
+// - *FirewallDefaults, nil if the network has no mode set (Options default applies)
+// - error if the custom rule fields are not JSON arrays of objects
+func (n *NetworkRecord) GetFirewallDefaults() (*FirewallDefaults, error) {
+	if n.FirewallDefaultMode == "" {
+		return nil, nil
+	}
+
+	defaults := &FirewallDefaults{Mode: n.FirewallDefaultMode}
+	if n.FirewallDefaultOutbound != "" && n.FirewallDefaultOutbound != "null" {
+		if err := json.Unmarshal([]byte(n.FirewallDefaultOutbound), &defaults.Outbound); err != nil {
+			return nil, err
+		}
+	}
+	if n.FirewallDefaultInbound != "" && n.FirewallDefaultInbound != "null" {
+		if err := json.Unmarshal([]byte(n.FirewallDefaultInbound), &defaults.Inbound); err != nil {
+			return nil, err
+		}
+	}
+	return defaults, nil
+}
+
+// GetTargetGroups extracts the groups a firewall policy applies to.
+//
+// RETURNS:
+// - []string containing group names (empty = policy applies to every host)
+// - error if JSON parsing fails
+func (p *FirewallPolicyRecord) GetTargetGroups() ([]string, error) {
+	if p.TargetGroups == "" || p.TargetGroups == "null" {
+		return []string{}, nil
+	}
+
+	var groups []string
+	if err := json.Unmarshal([]byte(p.TargetGroups), &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetRules extracts the firewall rules of a policy in Nebula's native format.
+//
+// RETURNS:
+// - []map[string]interface{} containing rules
+// - error if JSON parsing fails
+func (p *FirewallPolicyRecord) GetRules() ([]map[string]interface{}, error) {
+	if p.Rules == "" || p.Rules == "null" {
+		return nil, nil
+	}
+
+	var rules []map[string]interface{}
+	if err := json.Unmarshal([]byte(p.Rules), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetRules extracts the firewall rules of a rule set in Nebula's native format.
+//
+// RETURNS:
+// - outbound: Array of outbound firewall rules
+// - inbound: Array of inbound firewall rules
+// - error if JSON parsing fails
+func (r *FirewallRuleSetRecord) GetRules() (outbound, inbound []map[string]interface{}, err error) {
+	if r.Outbound != "" && r.Outbound != "null" {
+		if err := json.Unmarshal([]byte(r.Outbound), &outbound); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if r.Inbound != "" && r.Inbound != "null" {
+		if err := json.Unmarshal([]byte(r.Inbound), &inbound); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return outbound, inbound, nil
+}
+
+// GetConfigOverrides extracts the host-specific config overrides from the JSON field.
+//
+// RETURNS:
+// - map[string]interface{} to merge over generated configs (nil if unset)
+// - error if the field is not a JSON object
+func (h *HostRecord) GetConfigOverrides() (map[string]interface{}, error) {
+	return ParseConfigOverrides(h.ConfigOverrides)
+}
+
+// GetFirewallSettings extracts the host-specific firewall settings from the JSON field.
+//
+// RETURNS:
+// - FirewallSettings (zero value if unset)
+// - error if the field is not a valid settings object
+func (h *HostRecord) GetFirewallSettings() (FirewallSettings, error) {
+	return ParseFirewallSettings(h.FirewallSettings)
+}
+
+// ParseFirewallSettings parses a firewall settings JSON document.
+// Unknown keys are rejected so typos don't silently fall back to Nebula defaults.
+//
+// EMPTY HANDLING:
+// Empty or null JSON returns the zero value (nothing set, not error).
+//
+// RETURNS:
+// - FirewallSettings parsed settings (values are not validated)
+// - error if the JSON is invalid, not an object or has unknown keys
+func ParseFirewallSettings(raw string) (FirewallSettings, error) {
+	var settings FirewallSettings
+	if raw == "" || raw == "null" {
+		return settings, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return FirewallSettings{}, fmt.Errorf("firewall settings must be a JSON object: %w", err)
+	}
+	return settings, nil
+}
+
+// ParseConfigOverrides parses a config override JSON document.
+// Overrides mirror the Nebula YAML structure, e.g. {"tun": {"mtu": 1400}}.
+//
+// EMPTY HANDLING:
+// Empty or null JSON returns nil (no overrides, not error).
+//
+// RETURNS:
+// - map[string]interface{} parsed overrides
+// - error if the JSON is invalid or not an object
+func ParseConfigOverrides(raw string) (map[string]interface{}, error) {
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+
+	var overrides map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("config overrides must be a JSON object: %w", err)
+	}
+	return overrides, nil
+}
+
 // GetGroups extracts the groups array from the JSON field.
 // Groups are stored as JSON to leverage PocketBase's native JSON handling.
 //
@@ -211,6 +598,60 @@ func (h *HostRecord) SetGroups(groups []string) error {
 	return nil
 }
 
+// GetPublicEndpoints returns every public endpoint of the host, primary first.
+// The primary public_host_port is followed by public_endpoints, duplicates removed.
+//
+// MULTI-HOMED HOSTS:
+// Nebula tries every endpoint in static_host_map, so dual-stacked or multi-homed
+// lighthouses list all their addresses to avoid a single point of failure.
+//
+// RETURNS:
+// - []string containing endpoints (empty if none configured)
+// - error if JSON parsing fails
+func (h *HostRecord) GetPublicEndpoints() ([]string, error) {
+	endpoints := []string{}
+	if h.PublicHostPort != "" {
+		endpoints = append(endpoints, h.PublicHostPort)
+	}
+
+	if h.PublicEndpoints == "" || h.PublicEndpoints == "null" {
+		return endpoints, nil
+	}
+
+	var additional []string
+	if err := json.Unmarshal([]byte(h.PublicEndpoints), &additional); err != nil {
+		return nil, err
+	}
+	for _, endpoint := range additional {
+		if !slices.Contains(endpoints, endpoint) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// GetAdvertiseAddrs extracts the advertised public addresses from the JSON field.
+// Hosts behind port-forwarded firewalls report these to lighthouses in addition
+// to the addresses lighthouses observe.
+//
+// RETURNS:
+// - []string containing IP:PORT addresses
+// - error if JSON parsing fails
+//
+// EMPTY HANDLING:
+// Empty or null JSON returns empty slice (not error).
+func (h *HostRecord) GetAdvertiseAddrs() ([]string, error) {
+	if h.AdvertiseAddrs == "" || h.AdvertiseAddrs == "null" {
+		return []string{}, nil
+	}
+
+	var addrs []string
+	if err := json.Unmarshal([]byte(h.AdvertiseAddrs), &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
 // GetFirewallRules extracts firewall rules from JSON fields.
 // Nebula's native firewall format is stored directly without abstraction.
 //
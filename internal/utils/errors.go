@@ -0,0 +1,14 @@
+package utils
+
+// SplitJoinedError flattens an errors.Join error into its individual messages.
+// Any other error becomes a single message.
+func SplitJoinedError(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		messages := []string{}
+		for _, inner := range joined.Unwrap() {
+			messages = append(messages, inner.Error())
+		}
+		return messages
+	}
+	return []string{err.Error()}
+}
@@ -28,14 +28,19 @@ package pbnebula
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/skeeeon/pb-nebula/internal/cert"
 	"github.com/skeeeon/pb-nebula/internal/collections"
+	"github.com/skeeeon/pb-nebula/internal/commands"
 	"github.com/skeeeon/pb-nebula/internal/config"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
 	"github.com/skeeeon/pb-nebula/internal/ipam"
+	"github.com/skeeeon/pb-nebula/internal/routes"
 	"github.com/skeeeon/pb-nebula/internal/sync"
+	"github.com/skeeeon/pb-nebula/internal/types"
 	"github.com/skeeeon/pb-nebula/internal/utils"
 )
 
@@ -44,16 +49,18 @@ import (
 //
 // INITIALIZATION SEQUENCE:
 // 1. Validate and apply default options
-// 2. Register OnBootstrap hook for component initialization
-// 3. On bootstrap: Create collections → Initialize managers → Setup sync hooks
+// 2. Register CLI commands (must happen before PocketBase parses the command line)
+// 3. Register OnBootstrap hook for component initialization
+// 4. On bootstrap: Create collections → Initialize managers → Setup sync hooks
 //
 // COMPONENT INITIALIZATION ORDER:
 // Collections must exist before managers can use them:
-// 1. Collections (CA → Networks → Hosts)
+// 1. Collections (CA → Templates → Networks → Policies → Rule Sets → Hosts → Regeneration Jobs)
 // 2. Certificate manager (stateless)
 // 3. Config generator (stateless)
 // 4. IPAM manager (needs collections)
 // 5. Sync manager (needs all components)
+// 6. Routes manager (needs managers, bound on serve)
 //
 // PARAMETERS:
 //   - app: PocketBase application instance
@@ -65,6 +72,7 @@ import (
 //
 // SIDE EFFECTS:
 // - Registers PocketBase OnBootstrap hook
+// - Adds the `nebula` command to PocketBase's CLI
 // - Creates collections on first run
 // - Registers event hooks for automatic certificate/config generation
 //
@@ -83,6 +91,12 @@ func Setup(app *pocketbase.PocketBase, options Options) error {
 		return WrapError(err, "invalid options")
 	}
 
+	// Register CLI commands
+	commandsManager := commands.NewManager(app, options, utils.NewLogger(options.LogToConsole))
+	if err := commandsManager.SetupCommands(); err != nil {
+		return WrapError(err, "failed to setup commands")
+	}
+
 	// Register bootstrap hook for initialization
 	app.OnBootstrap().BindFunc(func(e *core.BootstrapEvent) error {
 		if err := e.Next(); err != nil {
@@ -105,6 +119,7 @@ func Setup(app *pocketbase.PocketBase, options Options) error {
 // 4. Create stateful manager (IPAM - needs database access)
 // 5. Setup sync manager (coordinates everything)
 // 6. Register PocketBase hooks (automatic behavior)
+// 7. Register REST routes (inspection and admin operations)
 //
 // PARAMETERS:
 //   - app: PocketBase application instance
@@ -124,14 +139,18 @@ func initializeComponents(app *pocketbase.PocketBase, options Options) error {
 	if err := collectionManager.InitializeCollections(); err != nil {
 		return WrapError(err, "failed to initialize collections")
 	}
-	logger.Success("Collections initialized: %s, %s, %s",
+	logger.Success("Collections initialized: %s, %s, %s, %s, %s, %s, %s",
 		options.CACollectionName,
+		options.TemplateCollectionName,
 		options.NetworkCollectionName,
-		options.HostCollectionName)
+		options.PolicyCollectionName,
+		options.RuleSetCollectionName,
+		options.HostCollectionName,
+		options.QueueCollectionName)
 
-	// Step 2: Create certificate manager (stateless)
+	// Step 2: Create certificate manager (stateless apart from clock settings)
 	logger.Info("Initializing certificate manager...")
-	certManager := cert.NewManager()
+	certManager := cert.NewManager(options.CertificateBackdate)
 	logger.Success("Certificate manager ready")
 
 	// Step 3: Create config generator (stateless)
@@ -156,13 +175,31 @@ func initializeComponents(app *pocketbase.PocketBase, options Options) error {
 	}
 	logger.Success("PocketBase hooks registered")
 
+	// Step 7: Setup REST routes (bound when the server starts)
+	logger.Info("Registering REST routes...")
+	routesManager := routes.NewManager(app, certManager, syncManager, options, logger)
+	if err := routesManager.SetupRoutes(); err != nil {
+		return WrapError(err, "failed to setup routes")
+	}
+	logger.Success("REST routes registered")
+
 	logger.Success("🎉 pb-nebula initialized successfully!")
-	logger.Info("Collections: %s, %s, %s",
+	logger.Info("Collections: %s, %s, %s, %s, %s, %s, %s",
 		options.CACollectionName,
+		options.TemplateCollectionName,
 		options.NetworkCollectionName,
-		options.HostCollectionName)
+		options.PolicyCollectionName,
+		options.RuleSetCollectionName,
+		options.HostCollectionName,
+		options.QueueCollectionName)
 	logger.Info("Default CA validity: %d years", options.DefaultCAValidityYears)
 	logger.Info("Default host validity: %d years", options.DefaultHostValidityYears)
+	if options.CertificateBackdate > 0 {
+		logger.Info("Certificate backdate: %s", options.CertificateBackdate)
+	} else {
+		logger.Info("Certificate backdate: disabled")
+	}
+	logger.Info("Regeneration queue: %d workers, %s debounce", options.RegenerationWorkers, options.RegenerationDebounce)
 
 	return nil
 }
@@ -173,6 +210,8 @@ func initializeComponents(app *pocketbase.PocketBase, options Options) error {
 // VALIDATION CHECKS:
 // - Collection names are not empty
 // - Validity periods are positive
+// - Certificate backdate is within 0-24h
+// - Regeneration debounce is within 0-1m, workers are positive
 // - Collection names don't conflict
 //
 // PARAMETERS:
@@ -192,12 +231,35 @@ func validateOptions(options Options) error {
 	if err := ValidateRequired(options.HostCollectionName, "HostCollectionName"); err != nil {
 		return err
 	}
+	if err := ValidateRequired(options.TemplateCollectionName, "TemplateCollectionName"); err != nil {
+		return err
+	}
+	if err := ValidateRequired(options.PolicyCollectionName, "PolicyCollectionName"); err != nil {
+		return err
+	}
+	if err := ValidateRequired(options.RuleSetCollectionName, "RuleSetCollectionName"); err != nil {
+		return err
+	}
+	if err := ValidateRequired(options.QueueCollectionName, "QueueCollectionName"); err != nil {
+		return err
+	}
 
 	// Ensure collection names are unique
-	if options.CACollectionName == options.NetworkCollectionName ||
-		options.CACollectionName == options.HostCollectionName ||
-		options.NetworkCollectionName == options.HostCollectionName {
-		return fmt.Errorf("collection names must be unique")
+	names := []string{
+		options.CACollectionName,
+		options.NetworkCollectionName,
+		options.HostCollectionName,
+		options.TemplateCollectionName,
+		options.PolicyCollectionName,
+		options.RuleSetCollectionName,
+		options.QueueCollectionName,
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("collection names must be unique")
+		}
+		seen[name] = true
 	}
 
 	// Validate validity periods
@@ -208,11 +270,39 @@ func validateOptions(options Options) error {
 		return fmt.Errorf("DefaultHostValidityYears must be positive, got %d", options.DefaultHostValidityYears)
 	}
 
+	// Validate clock skew window (a day of backdating would hide real clock problems)
+	if options.CertificateBackdate > 24*time.Hour {
+		return fmt.Errorf("CertificateBackdate must be at most 24h, got %s", options.CertificateBackdate)
+	}
+
+	// Validate regeneration queue (a long debounce would make configs lag behind changes)
+	if options.RegenerationDebounce < 0 || options.RegenerationDebounce > time.Minute {
+		return fmt.Errorf("RegenerationDebounce must be between 0 and 1m, got %s", options.RegenerationDebounce)
+	}
+	if options.RegenerationWorkers <= 0 {
+		return fmt.Errorf("RegenerationWorkers must be positive, got %d", options.RegenerationWorkers)
+	}
+
 	// Ensure host validity doesn't exceed CA validity
 	if options.DefaultHostValidityYears > options.DefaultCAValidityYears {
 		return fmt.Errorf("DefaultHostValidityYears (%d) cannot exceed DefaultCAValidityYears (%d)",
 			options.DefaultHostValidityYears, options.DefaultCAValidityYears)
 	}
 
+	// Validate default firewall rules
+	if err := firewall.ValidateDefaults(types.FirewallDefaults{
+		Mode:     options.DefaultFirewallMode,
+		Outbound: options.DefaultFirewallOutbound,
+		Inbound:  options.DefaultFirewallInbound,
+	}); err != nil {
+		return fmt.Errorf("invalid default firewall rules: %w", err)
+	}
+
 	return nil
 }
+
+// newSyncManager creates the sync manager used by the top-level Go APIs.
+// They report through their return values, so logging is quiet.
+func newSyncManager(app *pocketbase.PocketBase, options Options) *sync.Manager {
+	return sync.NewStandaloneManager(app, options, utils.NewLogger(false))
+}
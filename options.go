@@ -7,6 +7,13 @@ import (
 // Re-export Options type for external use
 type Options = types.Options
 
+// Default firewall modes (Options.DefaultFirewallMode, network firewall_default_mode)
+const (
+	FirewallDefaultsRecommended = types.FirewallDefaultsRecommended // Outbound any, inbound ICMP from any
+	FirewallDefaultsNone        = types.FirewallDefaultsNone        // No defaults - empty directions deny everything
+	FirewallDefaultsCustom      = types.FirewallDefaultsCustom      // DefaultFirewallOutbound/DefaultFirewallInbound
+)
+
 // DefaultOptions returns sensible defaults for Nebula certificate and config management.
 // These defaults follow the grug-brained philosophy: simple, predictable, and safe.
 //
@@ -16,6 +23,13 @@ type Options = types.Options
 // VALIDITY PERIODS:
 // - CA: 10 years (long-lived root of trust)
 // - Hosts: 1 year (shorter validity reduces exposure window)
+// - NotBefore backdated 5 minutes (tolerates hosts with slow clocks)
+//
+// REGENERATION QUEUE:
+// Network-wide regenerations coalesced for 2 seconds, 4 workers.
+//
+// FIREWALL:
+// Nebula recommended defaults for hosts without rules (outbound any, inbound ICMP).
 //
 // LOGGING:
 // Enabled by default for visibility during development and operations.
@@ -24,12 +38,22 @@ type Options = types.Options
 // - Options struct with production-ready defaults
 func DefaultOptions() Options {
 	return Options{
-		CACollectionName:      types.DefaultCACollectionName,
-		NetworkCollectionName: types.DefaultNetworkCollectionName,
-		HostCollectionName:    types.DefaultHostCollectionName,
+		CACollectionName:       types.DefaultCACollectionName,
+		NetworkCollectionName:  types.DefaultNetworkCollectionName,
+		HostCollectionName:     types.DefaultHostCollectionName,
+		TemplateCollectionName: types.DefaultTemplateCollectionName,
+		PolicyCollectionName:   types.DefaultPolicyCollectionName,
+		RuleSetCollectionName:  types.DefaultRuleSetCollectionName,
+		QueueCollectionName:    types.DefaultQueueCollectionName,
 
 		DefaultCAValidityYears:   types.DefaultCAValidityYears,
 		DefaultHostValidityYears: types.DefaultHostValidityYears,
+		CertificateBackdate:      types.DefaultCertificateBackdate,
+
+		RegenerationDebounce: types.DefaultRegenerationDebounce,
+		RegenerationWorkers:  types.DefaultRegenerationWorkers,
+
+		DefaultFirewallMode: types.FirewallDefaultsRecommended,
 
 		LogToConsole: true,
 
@@ -62,6 +86,18 @@ func applyDefaultOptions(options Options) Options {
 	if options.HostCollectionName == "" {
 		options.HostCollectionName = defaults.HostCollectionName
 	}
+	if options.TemplateCollectionName == "" {
+		options.TemplateCollectionName = defaults.TemplateCollectionName
+	}
+	if options.PolicyCollectionName == "" {
+		options.PolicyCollectionName = defaults.PolicyCollectionName
+	}
+	if options.RuleSetCollectionName == "" {
+		options.RuleSetCollectionName = defaults.RuleSetCollectionName
+	}
+	if options.QueueCollectionName == "" {
+		options.QueueCollectionName = defaults.QueueCollectionName
+	}
 
 	// Apply validity defaults
 	if options.DefaultCAValidityYears <= 0 {
@@ -71,5 +107,23 @@ func applyDefaultOptions(options Options) Options {
 		options.DefaultHostValidityYears = defaults.DefaultHostValidityYears
 	}
 
+	// Apply regeneration queue defaults
+	if options.RegenerationWorkers <= 0 {
+		options.RegenerationWorkers = defaults.RegenerationWorkers
+	}
+
+	// Apply clock skew default (a negative backdate disables backdating)
+	if options.CertificateBackdate == 0 {
+		options.CertificateBackdate = defaults.CertificateBackdate
+	}
+
+	// Apply firewall defaults (custom rules are kept as given)
+	if options.DefaultFirewallMode == "" {
+		options.DefaultFirewallMode = defaults.DefaultFirewallMode
+	}
+
+	// RegenerationDebounce is intentionally not defaulted: zero is a valid
+	// "no debouncing" choice, and DefaultOptions() already sets it.
+
 	return options
 }
@@ -0,0 +1,41 @@
+package pbnebula
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/firewall"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+)
+
+// Re-export reachability types for external use
+type (
+	ReachabilityQuery   = sync.ReachabilityQuery // Host pair, protocol and port to check
+	ReachabilityVerdict = firewall.Verdict       // Answer with the matching rule in each direction
+	FirewallPeer        = firewall.Peer          // Host identity as seen by Nebula's firewall
+	FirewallRuleMatch   = firewall.RuleMatch     // Outcome of evaluating one direction
+)
+
+// CheckReachability answers "can host A reach host B on PROTO/PORT?".
+// It walks A's generated outbound rules against B's certificate identity and B's
+// generated inbound rules against A's, and explains which rule matched in each direction.
+// Also available to superusers as GET /api/nebula/reachability.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup (collection names)
+//   - query: Host record IDs, protocol (tcp, udp, icmp) and port
+//
+// RETURNS:
+// - ReachabilityVerdict with Allowed, the matching rules and a human-readable explanation
+// - error if a host is missing, has no certificate/config yet, or the query is invalid
+//
+// SIDE EFFECTS: None (read-only)
+func CheckReachability(app *pocketbase.PocketBase, options Options, query ReachabilityQuery) (*ReachabilityVerdict, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.CheckReachability(query)
+}
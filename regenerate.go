@@ -0,0 +1,68 @@
+package pbnebula
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+)
+
+// Re-export bulk regeneration types for external use
+type (
+	RegenerateRequest  = sync.RegenerateRequest  // Scope (host, network or all), re-sign and dry-run flags
+	RegenerateResult   = sync.RegenerateResult   // Outcome for a single host
+	RegenerateReport   = sync.RegenerateReport   // Summary with per-host results
+	RegenerateProgress = sync.RegenerateProgress // Called after each host
+	QueueStats         = sync.QueueStats         // Regeneration queue depth and worker state
+)
+
+// Regenerate force-regenerates configs, or re-signs certificates, of a host, a network
+// or every host (empty HostID and NetworkID). Use it after changing generator
+// defaults or upgrading pb-nebula. Also available as `nebula regenerate` and to
+// superusers as POST /api/nebula/regenerate.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup
+//   - request: Scope, re-sign and dry-run flags
+//   - progress: Called after each host (nil for no progress reporting)
+//
+// RETURNS:
+// - RegenerateReport with per-host results (also returned alongside host failures)
+// - error if the scope cannot be loaded or any host failed
+//
+// SIDE EFFECTS: Saves regenerated host records (not in dry-run)
+func Regenerate(app *pocketbase.PocketBase, options Options, request RegenerateRequest, progress RegenerateProgress) (*RegenerateReport, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.Regenerate(request, progress)
+}
+
+// RegenerationQueueDepth returns the number of pending background regenerations.
+// Jobs are persisted, so this includes jobs a stopped server will resume on start.
+// Worker state is available to superusers as GET /api/nebula/queue.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup (collection names)
+//
+// RETURNS:
+// - Number of hosts waiting for or undergoing regeneration
+// - error if the queue collection cannot be counted
+//
+// SIDE EFFECTS: None (read-only)
+func RegenerationQueueDepth(app *pocketbase.PocketBase, options Options) (int, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return 0, WrapError(err, "invalid options")
+	}
+
+	count, err := app.CountRecords(options.QueueCollectionName)
+	if err != nil {
+		return 0, WrapError(err, "failed to count regeneration jobs")
+	}
+	return int(count), nil
+}
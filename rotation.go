@@ -0,0 +1,64 @@
+package pbnebula
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/skeeeon/pb-nebula/internal/sync"
+)
+
+// Re-export key rotation types for external use
+type (
+	KeyRotationResult = sync.KeyRotationResult // Old and new certificate of a rotated host
+)
+
+// RotateHostKeys gives a host a new key pair and certificate, blocklists the old
+// certificate and regenerates every config of the host's network. Use it when a
+// machine is compromised. Also available to superusers as
+// POST /api/nebula/hosts/{id}/rotate-keys.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup
+//   - hostID: Host record ID
+//
+// RETURNS:
+// - KeyRotationResult describing old and new certificates
+// - error if the host cannot be found or rotation fails
+// - KeyRotationResult and an error wrapping ErrConfigsNotRegenerated if only the config regeneration failed
+//
+// SIDE EFFECTS: Saves the host, the network blocklist and every host config in the network
+func RotateHostKeys(app *pocketbase.PocketBase, options Options, hostID string) (*KeyRotationResult, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.RotateHostKeys(hostID)
+}
+
+// RotateNetworkKeys rotates the keys of every host in a network, all-or-nothing:
+// if one host fails, no host is changed. Also available to superusers as
+// POST /api/nebula/networks/{id}/rotate-keys.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance (after pb-nebula Setup and bootstrap)
+//   - options: Options passed to Setup
+//   - networkID: Network record ID
+//
+// RETURNS:
+// - []KeyRotationResult for every rotated host
+// - error if the network cannot be found or any host failed
+// - []KeyRotationResult and an error wrapping ErrConfigsNotRegenerated if only the config regeneration failed
+//
+// SIDE EFFECTS: Saves every host and the blocklist of the network
+func RotateNetworkKeys(app *pocketbase.PocketBase, options Options, networkID string) ([]KeyRotationResult, error) {
+	options = applyDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, WrapError(err, "invalid options")
+	}
+
+	syncManager := newSyncManager(app, options)
+
+	return syncManager.RotateNetworkKeys(networkID)
+}